@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// azureBaseURL is the Azure Resource Manager endpoint.
+	azureBaseURL = "https://management.azure.com"
+	// azureDNSAPIVersion is the version of the Microsoft.Network/dnsZones API.
+	azureDNSAPIVersion = "2018-05-01"
+	// azureLoginURL is the Microsoft Entra ID endpoint issuing tokens for
+	// client secrets.
+	azureLoginURL = "https://login.microsoftonline.com"
+	// azureIMDSTokenURL is the token endpoint of the instance metadata
+	// service, which serves the tokens of managed identities.
+	azureIMDSTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// AzureProvider updates records through the Azure DNS API.
+type AzureProvider struct {
+	client *restClient
+}
+
+// NewAzureProvider creates a new Azure DNS provider. It authenticates with
+// the client secret of a service principal if AZURE_CLIENT_SECRET is set,
+// and with a managed identity otherwise.
+func NewAzureProvider(config Config) (*AzureProvider, error) {
+	if config.AzureSubscriptionID == "" || config.AzureResourceGroup == "" {
+		return nil, fmt.Errorf("Azure provider requires AZURE_SUBSCRIPTION_ID and AZURE_RESOURCE_GROUP")
+	}
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	var token *accessToken
+	if config.AzureClientSecret != "" {
+		if config.AzureTenantID == "" || config.AzureClientID == "" {
+			return nil, fmt.Errorf("Azure provider requires AZURE_TENANT_ID and AZURE_CLIENT_ID with AZURE_CLIENT_SECRET")
+		}
+		tokenURL := azureLoginURL + "/" + url.PathEscape(config.AzureTenantID) + "/oauth2/v2.0/token"
+		token = newAccessToken(azureClientSecretToken(httpClient, tokenURL, config.AzureClientID, config.AzureClientSecret))
+	} else {
+		// AZURE_CLIENT_ID selects a user-assigned identity.
+		token = newAccessToken(azureManagedIdentityToken(httpClient, azureIMDSTokenURL, config.AzureClientID))
+	}
+
+	baseURL := azureBaseURL + "/subscriptions/" + url.PathEscape(config.AzureSubscriptionID) +
+		"/resourceGroups/" + url.PathEscape(config.AzureResourceGroup) + "/providers/Microsoft.Network/dnsZones"
+	client := newRESTClient("Azure DNS", baseURL, nil, azureErrorMessage)
+	client.authorize = token.authorize
+	return &AzureProvider{client: client}, nil
+}
+
+// azureErrorMessage extracts the message of an error response.
+func azureErrorMessage(body []byte) string {
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	if resp.Error.Code != "" && resp.Error.Message != "" {
+		return resp.Error.Code + ": " + resp.Error.Message
+	}
+	return resp.Error.Message
+}
+
+// azureClientSecretToken returns a function obtaining access tokens for a
+// service principal with the client credentials grant.
+func azureClientSecretToken(httpClient *http.Client, tokenURL, clientID, clientSecret string) func(ctx context.Context) (string, time.Duration, error) {
+	return func(ctx context.Context) (string, time.Duration, error) {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {azureBaseURL + "/.default"},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return requestToken(httpClient, req, "Azure")
+	}
+}
+
+// azureManagedIdentityToken returns a function obtaining access tokens for
+// the managed identity of the host. clientID selects a user-assigned
+// identity; the system-assigned identity is used if it is empty.
+func azureManagedIdentityToken(httpClient *http.Client, tokenURL, clientID string) func(ctx context.Context) (string, time.Duration, error) {
+	return func(ctx context.Context) (string, time.Duration, error) {
+		query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureBaseURL + "/"}}
+		if clientID != "" {
+			query.Set("client_id", clientID)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata", "true")
+		return requestToken(httpClient, req, "Azure")
+	}
+}
+
+// azureRecordSet is a record set as represented by the Azure DNS API.
+type azureRecordSet struct {
+	Properties struct {
+		TTL      int `json:"TTL"`
+		ARecords []struct {
+			IPv4Address string `json:"ipv4Address"`
+		} `json:"ARecords,omitempty"`
+		AAAARecords []struct {
+			IPv6Address string `json:"ipv6Address"`
+		} `json:"AAAARecords,omitempty"`
+	} `json:"properties"`
+}
+
+// UpdateRecord updates an A or AAAA record using the Azure provider. The
+// record set is created or replaced with a single PUT request.
+func (a *AzureProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+
+	properties := map[string]interface{}{"TTL": int(ttl.Seconds())}
+	if recordType == RecordTypeA {
+		properties["ARecords"] = []map[string]string{{"ipv4Address": ip}}
+	} else {
+		properties["AAAARecords"] = []map[string]string{{"ipv6Address": ip}}
+	}
+	return a.client.do(ctx, http.MethodPut, a.recordSetPath(zone, name, recordType), map[string]interface{}{"properties": properties}, nil)
+}
+
+// GetRecords returns the addresses of the record set with the given name and
+// type.
+func (a *AzureProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	var rrset azureRecordSet
+	err := a.client.do(ctx, http.MethodGet, a.recordSetPath(zone, name, recordType), nil, &rrset)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, rec := range rrset.Properties.ARecords {
+		addrs = append(addrs, rec.IPv4Address)
+	}
+	for _, rec := range rrset.Properties.AAAARecords {
+		addrs = append(addrs, rec.IPv6Address)
+	}
+	return addrs, nil
+}
+
+// recordSetPath returns the API path of a record set, which Azure DNS
+// identifies by its name relative to the zone.
+func (a *AzureProvider) recordSetPath(zone, name, recordType string) string {
+	return "/" + url.PathEscape(strings.TrimSuffix(zone, ".")) + "/" + recordType + "/" +
+		url.PathEscape(relativeRecordName(name, zone, "@")) + "?api-version=" + azureDNSAPIVersion
+}
@@ -0,0 +1,125 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeAzure serves zone example.com containing an A record set for "home".
+func fakeAzure(t *testing.T) (*AzureProvider, *fakeAPI) {
+	t.Helper()
+	p, err := NewAzureProvider(Config{AzureSubscriptionID: "sub", AzureResourceGroup: "dns"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.client.authorize = newAccessToken(func(ctx context.Context) (string, time.Duration, error) {
+		return "token", time.Hour, nil
+	}).authorize
+
+	f := &fakeAPI{handle: func(r *http.Request) (int, interface{}) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			return http.StatusUnauthorized, map[string]interface{}{"error": map[string]string{"code": "InvalidAuthenticationToken", "message": "The access token is invalid."}}
+		}
+		if r.URL.Query().Get("api-version") != azureDNSAPIVersion {
+			return http.StatusBadRequest, map[string]interface{}{"error": map[string]string{"code": "MissingApiVersionParameter"}}
+		}
+		switch {
+		case r.Method == http.MethodPut:
+			return http.StatusOK, nil
+		case r.URL.Path == "/example.com/A/home":
+			return http.StatusOK, map[string]interface{}{"properties": map[string]interface{}{
+				"TTL":      3600,
+				"ARecords": []map[string]string{{"ipv4Address": "198.51.100.1"}},
+			}}
+		default:
+			return http.StatusNotFound, map[string]interface{}{"error": map[string]string{"code": "NotFound", "message": "The resource record was not found."}}
+		}
+	}}
+	f.start(t, p.client)
+	return p, f
+}
+
+func TestAzureProvider_UpdateRecord(t *testing.T) {
+	p, f := fakeAzure(t)
+
+	if err := p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.UpdateRecord(context.Background(), "example.com", "@", RecordTypeAAAA, "2001:db8::1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertWrites(t, f, []string{
+		"PUT /example.com/A/home?api-version=" + azureDNSAPIVersion,
+		"PUT /example.com/AAAA/@?api-version=" + azureDNSAPIVersion,
+	}, []map[string]interface{}{
+		{"properties": map[string]interface{}{"TTL": float64(60), "ARecords": []interface{}{map[string]interface{}{"ipv4Address": "203.0.113.1"}}}},
+		{"properties": map[string]interface{}{"TTL": float64(60), "AAAARecords": []interface{}{map[string]interface{}{"ipv6Address": "2001:db8::1"}}}},
+	})
+}
+
+func TestAzureProvider_GetRecords(t *testing.T) {
+	p, _ := fakeAzure(t)
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v, %v", addrs, err)
+	}
+	addrs, err = p.GetRecords(context.Background(), "example.com", "home", RecordTypeAAAA)
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("expected no addresses for missing record set, got %v, %v", addrs, err)
+	}
+
+	p.client.authorize = newAccessToken(func(ctx context.Context) (string, time.Duration, error) {
+		return "expired", time.Hour, nil
+	}).authorize
+	_, err = p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "InvalidAuthenticationToken: The access token is invalid." {
+		t.Errorf("expected HTTP 401 with code and message, got %v", err)
+	}
+}
+
+func TestNewAzureProvider(t *testing.T) {
+	if _, err := NewAzureProvider(Config{AzureSubscriptionID: "sub"}); err == nil {
+		t.Error("expected error without resource group")
+	}
+	if _, err := NewAzureProvider(Config{AzureSubscriptionID: "sub", AzureResourceGroup: "dns", AzureClientSecret: "secret"}); err == nil {
+		t.Error("expected error for client secret without tenant and client ID")
+	}
+}
+
+func TestAzureTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.FormValue("client_secret") == "secret" && r.FormValue("scope") == "https://management.azure.com/.default":
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "sp-token", "expires_in": 3599})
+		case r.Method == http.MethodGet && r.Header.Get("Metadata") == "true" && r.URL.Query().Get("client_id") == "identity":
+			// The instance metadata service sends expires_in as a string.
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "mi-token", "expires_in": "86399"})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."})
+		}
+	}))
+	defer srv.Close()
+
+	token, lifetime, err := azureClientSecretToken(srv.Client(), srv.URL, "client", "secret")(context.Background())
+	if err != nil || token != "sp-token" || lifetime != 3599*time.Second {
+		t.Errorf("expected service principal token, got %q, %v, %v", token, lifetime, err)
+	}
+	token, lifetime, err = azureManagedIdentityToken(srv.Client(), srv.URL, "identity")(context.Background())
+	if err != nil || token != "mi-token" || lifetime != 86399*time.Second {
+		t.Errorf("expected managed identity token, got %q, %v, %v", token, lifetime, err)
+	}
+
+	_, _, err = azureClientSecretToken(srv.Client(), srv.URL, "client", "wrong")(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "AADSTS7000215: Invalid client secret provided." {
+		t.Errorf("expected HTTP 400 with description, got %v", err)
+	}
+}
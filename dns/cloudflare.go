@@ -2,43 +2,326 @@ package dns
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/libdns/cloudflare"
-	"github.com/libdns/libdns"
 )
 
-// CloudflareProvider wraps the libdns Cloudflare provider.
+// cloudflareBaseURL is the Cloudflare API v4 endpoint.
+const cloudflareBaseURL = "https://api.cloudflare.com/client/v4"
+
+// CloudflareProvider updates records through the Cloudflare API.
 type CloudflareProvider struct {
-	provider *cloudflare.Provider
+	client *restClient
+
+	mu      sync.Mutex
+	zoneIDs map[string]string
+	options map[string]CloudflareRecordOptions // by record name
+}
+
+// CloudflareRecordOptions are Cloudflare-specific settings of a record.
+// Settings left unset keep their current value on existing records and
+// Cloudflare's default on new ones.
+type CloudflareRecordOptions struct {
+	Proxied *bool
+	Comment *string
+	Tags    []string // "name:value" pairs
 }
 
-// NewCloudflareProvider creates a new Cloudflare DNS provider.
+// NewCloudflareProvider creates a new Cloudflare DNS provider. It
+// authenticates with CF_API_TOKEN if set, and with the legacy CF_EMAIL and
+// CF_API_KEY otherwise.
 func NewCloudflareProvider(config Config) (*CloudflareProvider, error) {
-	if config.CFAPIToken == "" {
-		return nil, fmt.Errorf("Cloudflare provider requires CF_API_TOKEN")
+	if config.CFAPIToken == "" && (config.CFEmail == "" || config.CFAPIKey == "") {
+		return nil, fmt.Errorf("Cloudflare provider requires CF_API_TOKEN, or CF_EMAIL and CF_API_KEY")
 	}
-	
-	provider := &cloudflare.Provider{
-		APIToken: config.CFAPIToken,
+
+	header := http.Header{"Authorization": {"Bearer " + config.CFAPIToken}}
+	if config.CFAPIToken == "" {
+		header = http.Header{"X-Auth-Email": {config.CFEmail}, "X-Auth-Key": {config.CFAPIKey}}
 	}
-	
 	return &CloudflareProvider{
-		provider: provider,
+		client:  newRESTClient("Cloudflare", cloudflareBaseURL, header, cloudflareErrorMessage),
+		zoneIDs: make(map[string]string),
+		options: make(map[string]CloudflareRecordOptions),
 	}, nil
 }
 
-// UpdateARecord updates an A record using the Cloudflare provider.
-func (c *CloudflareProvider) UpdateARecord(ctx context.Context, zone, name, ip string, ttl time.Duration) error {
-	record, err := createRecord(name, zone, ip, ttl)
+// SetRecordOptions sets the options applied on every update of the record
+// with the given fully qualified name.
+func (c *CloudflareProvider) SetRecordOptions(name string, options CloudflareRecordOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options[recordKey(name)] = options
+}
+
+// recordOptions returns the options of the record with the given fully
+// qualified name.
+func (c *CloudflareProvider) recordOptions(name string) CloudflareRecordOptions {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.options[recordKey(name)]
+}
+
+// cloudflareResponse is the envelope of every Cloudflare API response.
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// message joins the errors of the response.
+func (r *cloudflareResponse) message() string {
+	var messages []string
+	for _, e := range r.Errors {
+		messages = append(messages, fmt.Sprintf("%s (code %d)", e.Message, e.Code))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// cloudflareErrorMessage extracts the message of an error response.
+func cloudflareErrorMessage(body []byte) string {
+	var resp cloudflareResponse
+	_ = json.Unmarshal(body, &resp)
+	return resp.message()
+}
+
+// cloudflareRecord is a DNS record as represented by the Cloudflare API.
+type cloudflareRecord struct {
+	ID      string   `json:"id,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Content string   `json:"content,omitempty"`
+	TTL     int      `json:"ttl,omitempty"`
+	Proxied *bool    `json:"proxied,omitempty"`
+	Comment *string  `json:"comment,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// cloudflareBatch is the body of a batch request. Cloudflare applies a
+// batch in a single transaction.
+type cloudflareBatch struct {
+	Patches []cloudflareRecord `json:"patches,omitempty"`
+	Posts   []cloudflareRecord `json:"posts,omitempty"`
+}
+
+// UpdateRecord updates an A or AAAA record using the Cloudflare provider.
+func (c *CloudflareProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	return c.UpdateRecords(ctx, zone, []Record{{Name: name, Type: recordType, IP: ip, TTL: ttl}})[0]
+}
+
+// UpdateRecords upserts records with a single batch request. Existing
+// records are patched in place; missing ones are created.
+func (c *CloudflareProvider) UpdateRecords(ctx context.Context, zone string, records []Record) []error {
+	errs := make([]error, len(records))
+	wanted := make([]cloudflareRecord, len(records))
+	var indexes []int
+	for i, rec := range records {
+		record, err := createRecord(rec.Name, zone, rec.Type, rec.IP, rec.TTL)
+		if err != nil {
+			errs[i] = Permanent(err)
+			continue
+		}
+		fqdn := strings.TrimSuffix(absoluteName(rec.Name, zone), ".")
+		options := c.recordOptions(fqdn)
+		wanted[i] = cloudflareRecord{
+			Type:    record.Type,
+			Name:    fqdn,
+			Content: record.Value,
+			TTL:     cloudflareTTL(record.TTL),
+			Proxied: options.Proxied,
+			Comment: options.Comment,
+			Tags:    options.Tags,
+		}
+		indexes = append(indexes, i)
+	}
+	if len(indexes) == 0 {
+		return errs
+	}
+
+	setAll := func(err error) []error {
+		for _, i := range indexes {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	zoneID, err := c.zoneID(ctx, zone)
+	if err != nil {
+		return setAll(err)
+	}
+
+	var batchIndexes []int
+	var ops []cloudflareBatch
+	for _, i := range indexes {
+		existing, err := c.findRecords(ctx, zoneID, wanted[i].Name, wanted[i].Type)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batchIndexes = append(batchIndexes, i)
+		ops = append(ops, cloudflareUpsert(wanted[i], existing))
+	}
+	if len(ops) == 0 {
+		return errs
+	}
+
+	return applyBatch(ops, batchIndexes, errs, func(ops []cloudflareBatch) error {
+		var batch cloudflareBatch
+		for _, op := range ops {
+			batch.Patches = append(batch.Patches, op.Patches...)
+			batch.Posts = append(batch.Posts, op.Posts...)
+		}
+		return c.batch(ctx, zoneID, batch)
+	}, isRejectedBatch)
+}
+
+// GetRecords returns the contents of the records with the given name and
+// type.
+func (c *CloudflareProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	zoneID, err := c.zoneID(ctx, zone)
 	if err != nil {
+		return nil, err
+	}
+	found, err := c.findRecords(ctx, zoneID, strings.TrimSuffix(absoluteName(name, zone), "."), recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, rec := range found {
+		addrs = append(addrs, rec.Content)
+	}
+	return addrs, nil
+}
+
+// cloudflareUpsert returns the batch operation that makes the zone contain
+// record, given the existing records of its name and type. If there are
+// several, the one chosen by pickMatch is patched. Settings not given in
+// record are copied from the existing record, so the patch does not reset
+// them.
+func cloudflareUpsert(record cloudflareRecord, existing []cloudflareRecord) cloudflareBatch {
+	match := pickMatch(existing, record.Content,
+		func(r cloudflareRecord) string { return r.Content },
+		func(a, b cloudflareRecord) bool { return a.ID < b.ID })
+	if match == nil {
+		return cloudflareBatch{Posts: []cloudflareRecord{record}}
+	}
+
+	patch := cloudflareRecord{
+		ID:      match.ID,
+		Content: record.Content,
+		TTL:     record.TTL,
+		Proxied: record.Proxied,
+		Comment: record.Comment,
+		Tags:    record.Tags,
+	}
+	if patch.Proxied == nil {
+		patch.Proxied = match.Proxied
+	}
+	if patch.Comment == nil {
+		patch.Comment = match.Comment
+	}
+	if patch.Tags == nil {
+		patch.Tags = match.Tags
+	}
+	return cloudflareBatch{Patches: []cloudflareRecord{patch}}
+}
+
+// cloudflareTTL converts ttl to seconds. Cloudflare uses 1 for "automatic".
+func cloudflareTTL(ttl time.Duration) int {
+	if seconds := int(ttl.Seconds()); seconds > 0 {
+		return seconds
+	}
+	return 1
+}
+
+// isRejectedBatch reports whether Cloudflare rejected a batch because of
+// its contents.
+func isRejectedBatch(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && isClientError(apiErr.StatusCode)
+}
+
+// zoneID returns the ID of the zone named zone.
+func (c *CloudflareProvider) zoneID(ctx context.Context, zone string) (string, error) {
+	zone = strings.TrimSuffix(zone, ".")
+
+	c.mu.Lock()
+	id, ok := c.zoneIDs[zone]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {zone}}.Encode(), nil, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", Permanent(fmt.Errorf("zone %s not found", zone))
+	}
+
+	c.mu.Lock()
+	c.zoneIDs[zone] = zones[0].ID
+	c.mu.Unlock()
+	return zones[0].ID, nil
+}
+
+// findRecords returns the records of the zone with the given fully
+// qualified name and type.
+func (c *CloudflareProvider) findRecords(ctx context.Context, zoneID, fqdn, recordType string) ([]cloudflareRecord, error) {
+	query := url.Values{
+		"type":     {recordType},
+		"name":     {strings.ToLower(fqdn)},
+		"per_page": {"100"},
+	}
+	var found []cloudflareRecord
+	if err := c.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// batch submits a batch of record changes to the zone.
+func (c *CloudflareProvider) batch(ctx context.Context, zoneID string, batch cloudflareBatch) error {
+	return c.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records/batch", batch, nil)
+}
+
+// do sends an API request and decodes the result into out. Cloudflare
+// wraps every result in an envelope, which may report a failure even with a
+// 2xx response.
+func (c *CloudflareProvider) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var envelope cloudflareResponse
+	if err := c.client.do(ctx, method, path, body, &envelope); err != nil {
 		return err
 	}
-	
-	normalizedZone := normalizeZone(zone)
-	
-	// Use SetRecords to upsert the record
-	_, err = c.provider.SetRecords(ctx, normalizedZone, []libdns.Record{record})
-	return err
-}
\ No newline at end of file
+	if !envelope.Success {
+		message := envelope.message()
+		if message == "" {
+			message = "request failed"
+		}
+		return &APIError{Provider: "Cloudflare", StatusCode: http.StatusOK, Message: message}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(envelope.Result, out); err != nil {
+			return fmt.Errorf("invalid Cloudflare API response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCloudflare is an in-memory stand-in for the Cloudflare API.
+type fakeCloudflare struct {
+	mu       sync.Mutex
+	token    string
+	email    string // with apiKey, accepted instead of token if set
+	apiKey   string
+	zones    map[string]string // name -> ID
+	records  []cloudflareRecord
+	batches  []cloudflareBatch
+	rejected map[string]bool // record names the batch endpoint refuses
+	listed   []string        // name filters of record listings
+	nextID   int
+}
+
+func newFakeCloudflare(t *testing.T, f *fakeCloudflare) *CloudflareProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	p, err := NewCloudflareProvider(Config{CFAPIToken: f.token, CFEmail: f.email, CFAPIKey: f.apiKey})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.client.httpClient = srv.Client()
+	p.client.baseURL = srv.URL
+	return p
+}
+
+func (f *fakeCloudflare) reply(w http.ResponseWriter, status int, result interface{}, errMsg string) {
+	resp := map[string]interface{}{
+		"success":     status == http.StatusOK,
+		"errors":      []interface{}{},
+		"result":      result,
+		"result_info": map[string]int{"page": 1, "total_pages": 1},
+	}
+	if errMsg != "" {
+		resp["errors"] = []map[string]interface{}{{"code": 1000, "message": errMsg}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (f *fakeCloudflare) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.email != "" {
+		if r.Header.Get("X-Auth-Email") != f.email || r.Header.Get("X-Auth-Key") != f.apiKey {
+			f.reply(w, http.StatusForbidden, nil, "Unknown X-Auth-Key or X-Auth-Email")
+			return
+		}
+	} else if r.Header.Get("Authorization") != "Bearer "+f.token {
+		f.reply(w, http.StatusForbidden, nil, "Authentication error")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "zones":
+		var result []map[string]string
+		if id, ok := f.zones[r.URL.Query().Get("name")]; ok {
+			result = append(result, map[string]string{"id": id})
+		}
+		f.reply(w, http.StatusOK, result, "")
+	case len(parts) == 3 && parts[2] == "dns_records" && r.Method == http.MethodGet:
+		result := []cloudflareRecord{}
+		name := r.URL.Query().Get("name")
+		f.listed = append(f.listed, name)
+		for _, rec := range f.records {
+			if rec.Type == r.URL.Query().Get("type") && (name == "" || rec.Name == name) {
+				result = append(result, rec)
+			}
+		}
+		f.reply(w, http.StatusOK, result, "")
+	case len(parts) == 4 && parts[3] == "batch" && r.Method == http.MethodPost:
+		var batch cloudflareBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			f.reply(w, http.StatusBadRequest, nil, err.Error())
+			return
+		}
+		f.batches = append(f.batches, batch)
+		for _, rec := range batch.Posts {
+			if f.rejected[rec.Name] {
+				f.reply(w, http.StatusBadRequest, nil, "invalid record "+rec.Name)
+				return
+			}
+		}
+		for _, patch := range batch.Patches {
+			for i := range f.records {
+				if f.records[i].ID == patch.ID {
+					f.records[i].Content = patch.Content
+					f.records[i].TTL = patch.TTL
+					f.records[i].Proxied = patch.Proxied
+					f.records[i].Comment = patch.Comment
+					f.records[i].Tags = patch.Tags
+				}
+			}
+		}
+		for _, rec := range batch.Posts {
+			f.nextID++
+			rec.ID = fmt.Sprintf("new%d", f.nextID)
+			f.records = append(f.records, rec)
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{}, "")
+	default:
+		f.reply(w, http.StatusNotFound, nil, "not found")
+	}
+}
+
+func TestCloudflareProvider_UpdateRecordsSingleBatch(t *testing.T) {
+	f := &fakeCloudflare{
+		token: "token",
+		zones: map[string]string{"example.com": "zone1"},
+		records: []cloudflareRecord{
+			{ID: "rec1", Type: "A", Name: "foo.example.com", Content: "198.51.100.1", TTL: 300},
+		},
+	}
+	p := newFakeCloudflare(t, f)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "foo.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bar.example.com", Type: RecordTypeAAAA, IP: "2001:db8::1", TTL: time.Minute},
+		{Name: "baz.example.com", Type: RecordTypeAAAA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !IsPermanent(errs[2]) {
+		t.Errorf("expected permanent error for wrong address family, got %v", errs[2])
+	}
+	if len(f.batches) != 1 {
+		t.Fatalf("expected 1 batch, got %d", len(f.batches))
+	}
+	batch := f.batches[0]
+	if len(batch.Patches) != 1 || batch.Patches[0].ID != "rec1" || batch.Patches[0].Content != "203.0.113.1" || batch.Patches[0].TTL != 60 {
+		t.Errorf("unexpected patches %+v", batch.Patches)
+	}
+	if len(batch.Posts) != 1 || batch.Posts[0].Name != "bar.example.com" || batch.Posts[0].Type != "AAAA" {
+		t.Errorf("unexpected posts %+v", batch.Posts)
+	}
+}
+
+func TestCloudflareProvider_UpdateRecordsAttributesErrors(t *testing.T) {
+	f := &fakeCloudflare{
+		token:    "token",
+		zones:    map[string]string{"example.com": "zone1"},
+		rejected: map[string]bool{"bad.example.com": true},
+	}
+	p := newFakeCloudflare(t, f)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "good.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bad.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+
+	if errs[0] != nil {
+		t.Errorf("expected good record to be updated, got %v", errs[0])
+	}
+	var cfErr *APIError
+	if !errors.As(errs[1], &cfErr) || cfErr.HTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("expected HTTP 400 for bad record, got %v", errs[1])
+	}
+	// One failed batch followed by one batch per record.
+	if len(f.batches) != 3 {
+		t.Errorf("expected 3 batches, got %d", len(f.batches))
+	}
+}
+
+func TestCloudflareProvider_RecordOptions(t *testing.T) {
+	proxied, notProxied := true, false
+	comment, newComment := "home router", "updated by dns-updater"
+	f := &fakeCloudflare{
+		token: "token",
+		zones: map[string]string{"example.com": "zone1"},
+		records: []cloudflareRecord{
+			{ID: "rec1", Type: "A", Name: "keep.example.com", Content: "198.51.100.1", TTL: 1, Proxied: &proxied, Comment: &comment, Tags: []string{"env:home"}},
+			{ID: "rec2", Type: "A", Name: "set.example.com", Content: "198.51.100.1", TTL: 1, Proxied: &proxied, Comment: &comment, Tags: []string{"env:home"}},
+		},
+	}
+	p := newFakeCloudflare(t, f)
+	p.SetRecordOptions("set.example.com.", CloudflareRecordOptions{Proxied: &notProxied, Comment: &newComment})
+	p.SetRecordOptions("new.example.com", CloudflareRecordOptions{Proxied: &proxied, Tags: []string{"owner:ops"}})
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "keep", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "set", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "new", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		proxied bool
+		comment string
+		tags    []string
+	}{
+		{name: "keep.example.com", proxied: true, comment: comment, tags: []string{"env:home"}},
+		{name: "set.example.com", proxied: false, comment: newComment, tags: []string{"env:home"}},
+		{name: "new.example.com", proxied: true, tags: []string{"owner:ops"}},
+	}
+	for _, tt := range tests {
+		for _, rec := range f.records {
+			if rec.Name != tt.name {
+				continue
+			}
+			if rec.Content != "203.0.113.1" || rec.Proxied == nil || *rec.Proxied != tt.proxied || !reflect.DeepEqual(rec.Tags, tt.tags) {
+				t.Errorf("unexpected settings of %s: %+v", tt.name, rec)
+			}
+			if (tt.comment == "") != (rec.Comment == nil) || (rec.Comment != nil && *rec.Comment != tt.comment) {
+				t.Errorf("expected comment %q on %s, got %v", tt.comment, tt.name, rec.Comment)
+			}
+		}
+	}
+}
+
+func TestCloudflareProvider_UpdateRecordErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		zone      string
+		records   []cloudflareRecord
+		permanent bool
+		status    int
+	}{
+		{
+			name:   "bad token",
+			token:  "wrong",
+			zone:   "example.com",
+			status: http.StatusForbidden,
+		},
+		{
+			name:      "zone not found",
+			token:     "token",
+			zone:      "example.org",
+			permanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeCloudflare{token: "token", zones: map[string]string{"example.com": "zone1"}, records: tt.records}
+			p := newFakeCloudflare(t, f)
+			p.client.header.Set("Authorization", "Bearer "+tt.token)
+
+			err := p.UpdateRecord(context.Background(), tt.zone, "foo", RecordTypeA, "203.0.113.1", time.Minute)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("expected permanent=%v, got %v", tt.permanent, err)
+			}
+			var cfErr *APIError
+			if tt.status != 0 && (!errors.As(err, &cfErr) || cfErr.HTTPStatusCode() != tt.status) {
+				t.Errorf("expected HTTP %d, got %v", tt.status, err)
+			}
+			if len(f.batches) != 0 {
+				t.Errorf("expected no batch, got %d", len(f.batches))
+			}
+		})
+	}
+}
+
+func TestCloudflareProvider_UpdateRecordsListsOnlyBatchRecords(t *testing.T) {
+	f := &fakeCloudflare{token: "token", zones: map[string]string{"example.com": "zone1"}}
+	for i := 0; i < 50; i++ {
+		f.records = append(f.records, cloudflareRecord{
+			ID: fmt.Sprintf("rec%d", i), Type: "A", Name: fmt.Sprintf("host%d.example.com", i), Content: "198.51.100.1",
+		})
+	}
+	p := newFakeCloudflare(t, f)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "host7.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "Home.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("record %d: unexpected error: %v", i, err)
+		}
+	}
+	if want := []string{"host7.example.com", "home.example.com"}; !reflect.DeepEqual(f.listed, want) {
+		t.Errorf("expected listings filtered to %v, got %v", want, f.listed)
+	}
+}
+
+func TestCloudflareProvider_UpdateRecordMultipleRecords(t *testing.T) {
+	tests := []struct {
+		name      string
+		ip        string
+		patchedID string
+	}{
+		{name: "record holding the address", ip: "198.51.100.2", patchedID: "rec2"},
+		{name: "first record by ID", ip: "203.0.113.1", patchedID: "rec1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeCloudflare{
+				token: "token",
+				zones: map[string]string{"example.com": "zone1"},
+				records: []cloudflareRecord{
+					{ID: "rec3", Type: "A", Name: "foo.example.com", Content: "198.51.100.3"},
+					{ID: "rec1", Type: "A", Name: "foo.example.com", Content: "198.51.100.1"},
+					{ID: "rec2", Type: "A", Name: "foo.example.com", Content: "198.51.100.2"},
+				},
+			}
+			p := newFakeCloudflare(t, f)
+
+			if err := p.UpdateRecord(context.Background(), "example.com", "foo", RecordTypeA, tt.ip, time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(f.batches) != 1 || len(f.batches[0].Patches) != 1 || len(f.batches[0].Posts) != 0 {
+				t.Fatalf("expected a single patch, got %+v", f.batches)
+			}
+			if id := f.batches[0].Patches[0].ID; id != tt.patchedID {
+				t.Errorf("expected %s to be patched, got %s", tt.patchedID, id)
+			}
+		})
+	}
+}
+
+func TestCloudflareProvider_GetRecords(t *testing.T) {
+	f := &fakeCloudflare{
+		token: "token",
+		zones: map[string]string{"example.com": "zone1"},
+		records: []cloudflareRecord{
+			{ID: "rec1", Type: "A", Name: "foo.example.com", Content: "198.51.100.1"},
+			{ID: "rec2", Type: "AAAA", Name: "foo.example.com", Content: "2001:db8::1"},
+			{ID: "rec3", Type: "A", Name: "bar.example.com", Content: "198.51.100.2"},
+		},
+	}
+	p := newFakeCloudflare(t, f)
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "foo", RecordTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v", addrs)
+	}
+
+	addrs, err = p.GetRecords(context.Background(), "example.com", "missing.example.com", RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 0 {
+		t.Errorf("expected no addresses for missing record, got %v", addrs)
+	}
+}
+
+func TestCloudflareProvider_LegacyAuth(t *testing.T) {
+	f := &fakeCloudflare{
+		email:   "user@example.com",
+		apiKey:  "global-key",
+		zones:   map[string]string{"example.com": "zone1"},
+		records: []cloudflareRecord{{ID: "rec1", Type: "A", Name: "baz.subdomain.example.com", Content: "198.51.100.1", TTL: 60}},
+	}
+	p := newFakeCloudflare(t, f)
+
+	if err := p.UpdateRecord(context.Background(), "example.com", "baz.subdomain.example.com", RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.records[0].Content != "203.0.113.1" {
+		t.Errorf("expected record to be updated, got %+v", f.records[0])
+	}
+
+	p.client.header["X-Auth-Key"] = []string{"wrong"}
+	_, err := p.GetRecords(context.Background(), "example.net", "home", RecordTypeA)
+	var cfErr *APIError
+	if !errors.As(err, &cfErr) || cfErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected HTTP 403 for wrong API key, got %v", err)
+	}
+}
+
+func TestNewCloudflareProviderRequiresCredentials(t *testing.T) {
+	for _, config := range []Config{{}, {CFEmail: "user@example.com"}, {CFAPIKey: "global-key"}} {
+		if _, err := NewCloudflareProvider(config); err == nil {
+			t.Errorf("expected error for %+v, got nil", config)
+		}
+	}
+}
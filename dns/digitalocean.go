@@ -0,0 +1,127 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// digitalOceanBaseURL is the DigitalOcean API v2 endpoint.
+const digitalOceanBaseURL = "https://api.digitalocean.com/v2"
+
+// DigitalOceanProvider updates records through the DigitalOcean API.
+type DigitalOceanProvider struct {
+	client *restClient
+}
+
+// NewDigitalOceanProvider creates a new DigitalOcean DNS provider.
+func NewDigitalOceanProvider(config Config) (*DigitalOceanProvider, error) {
+	if config.DOAPIToken == "" {
+		return nil, fmt.Errorf("DigitalOcean provider requires DO_API_TOKEN")
+	}
+
+	header := http.Header{"Authorization": {"Bearer " + config.DOAPIToken}}
+	return &DigitalOceanProvider{
+		client: newRESTClient("DigitalOcean", digitalOceanBaseURL, header, digitalOceanErrorMessage),
+	}, nil
+}
+
+// digitalOceanErrorMessage extracts the message of an error response.
+func digitalOceanErrorMessage(body []byte) string {
+	var resp struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	return resp.Message
+}
+
+// digitalOceanRecord is a DNS record as represented by the DigitalOcean API.
+type digitalOceanRecord struct {
+	ID   int    `json:"id,omitempty"`
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"` // relative to the domain, "@" for the apex
+	Data string `json:"data"`
+	TTL  int    `json:"ttl"`
+}
+
+// UpdateRecord updates an A or AAAA record using the DigitalOcean provider.
+// An existing record is updated in place; a missing one is created.
+func (d *DigitalOceanProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+	domain := strings.TrimSuffix(zone, ".")
+
+	existing, err := d.findRecords(ctx, domain, name, zone, recordType)
+	if err != nil {
+		return err
+	}
+	match := pickMatch(existing, ip,
+		func(r digitalOceanRecord) string { return r.Data },
+		func(a, b digitalOceanRecord) bool { return a.ID < b.ID })
+
+	record := digitalOceanRecord{Data: ip, TTL: digitalOceanTTL(ttl)}
+	if match != nil {
+		return d.client.do(ctx, http.MethodPatch, fmt.Sprintf("/domains/%s/records/%d", url.PathEscape(domain), match.ID), record, nil)
+	}
+	record.Type = recordType
+	record.Name = relativeRecordName(name, zone, "@")
+	return d.client.do(ctx, http.MethodPost, "/domains/"+url.PathEscape(domain)+"/records", record, nil)
+}
+
+// GetRecords returns the contents of the records with the given name and
+// type.
+func (d *DigitalOceanProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	existing, err := d.findRecords(ctx, strings.TrimSuffix(zone, "."), name, zone, recordType)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, rec := range existing {
+		addrs = append(addrs, rec.Data)
+	}
+	return addrs, nil
+}
+
+// findRecords returns the records of the domain with the given name and
+// type. The API filters by fully qualified name.
+func (d *DigitalOceanProvider) findRecords(ctx context.Context, domain, name, zone, recordType string) ([]digitalOceanRecord, error) {
+	var records []digitalOceanRecord
+	for page := 1; ; page++ {
+		query := url.Values{
+			"type":     {recordType},
+			"name":     {strings.TrimSuffix(absoluteName(name, zone), ".")},
+			"page":     {fmt.Sprint(page)},
+			"per_page": {"200"},
+		}
+		var resp struct {
+			DomainRecords []digitalOceanRecord `json:"domain_records"`
+			Meta          struct {
+				Total int `json:"total"`
+			} `json:"meta"`
+		}
+		if err := d.client.do(ctx, http.MethodGet, "/domains/"+url.PathEscape(domain)+"/records?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		records = append(records, resp.DomainRecords...)
+		if len(resp.DomainRecords) == 0 || len(records) >= resp.Meta.Total {
+			return records, nil
+		}
+	}
+}
+
+// digitalOceanTTL converts ttl to seconds. DigitalOcean requires at least 30.
+func digitalOceanTTL(ttl time.Duration) int {
+	if seconds := int(ttl.Seconds()); seconds > 30 {
+		return seconds
+	}
+	return 30
+}
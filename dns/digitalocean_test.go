@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeDigitalOcean answers record listings of example.com with records.
+func fakeDigitalOcean(t *testing.T, records []digitalOceanRecord) (*DigitalOceanProvider, *fakeAPI) {
+	t.Helper()
+	p, err := NewDigitalOceanProvider(Config{DOAPIToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := &fakeAPI{handle: func(r *http.Request) (int, interface{}) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			return http.StatusUnauthorized, map[string]string{"id": "unauthorized", "message": "Unable to authenticate you"}
+		}
+		if r.URL.Path != "/domains/example.com/records" && r.Method == http.MethodGet {
+			return http.StatusNotFound, map[string]string{"id": "not_found", "message": "The resource you requested could not be found."}
+		}
+		switch r.Method {
+		case http.MethodGet:
+			var found []digitalOceanRecord
+			for _, rec := range records {
+				if rec.Type == r.URL.Query().Get("type") && rec.Name+".example.com" == r.URL.Query().Get("name") {
+					found = append(found, rec)
+				}
+			}
+			return http.StatusOK, map[string]interface{}{"domain_records": found, "meta": map[string]int{"total": len(found)}}
+		case http.MethodPost:
+			return http.StatusCreated, map[string]interface{}{"domain_record": map[string]int{"id": 3}}
+		default:
+			return http.StatusOK, map[string]interface{}{"domain_record": map[string]int{"id": 1}}
+		}
+	}}
+	f.start(t, p.client)
+	return p, f
+}
+
+func TestDigitalOceanProvider_UpdateRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  []digitalOceanRecord
+		ttl      time.Duration
+		calls    []string
+		bodies   []map[string]interface{}
+		expected error
+	}{
+		{
+			name:  "create",
+			ttl:   time.Minute,
+			calls: []string{"POST /domains/example.com/records"},
+			bodies: []map[string]interface{}{
+				{"type": "A", "name": "home", "data": "203.0.113.1", "ttl": float64(60)},
+			},
+		},
+		{
+			name:    "update",
+			records: []digitalOceanRecord{{ID: 7, Type: "A", Name: "home", Data: "198.51.100.1", TTL: 1800}},
+			ttl:     10 * time.Second,
+			calls:   []string{"PATCH /domains/example.com/records/7"},
+			bodies: []map[string]interface{}{
+				{"data": "203.0.113.1", "ttl": float64(30)},
+			},
+		},
+		{
+			name: "several records",
+			records: []digitalOceanRecord{
+				{ID: 8, Type: "A", Name: "home", Data: "198.51.100.2"},
+				{ID: 7, Type: "A", Name: "home", Data: "198.51.100.1"},
+			},
+			ttl:   time.Minute,
+			calls: []string{"PATCH /domains/example.com/records/7"},
+			bodies: []map[string]interface{}{
+				{"data": "203.0.113.1", "ttl": float64(60)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, f := fakeDigitalOcean(t, tt.records)
+
+			if err := p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", tt.ttl); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertWrites(t, f, tt.calls, tt.bodies)
+		})
+	}
+}
+
+func TestDigitalOceanProvider_UpdateRecordErrors(t *testing.T) {
+	p, f := fakeDigitalOcean(t, nil)
+
+	err := p.UpdateRecord(context.Background(), "example.org", "home", RecordTypeA, "203.0.113.1", time.Minute)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode() != http.StatusNotFound {
+		t.Errorf("expected HTTP 404 for unknown domain, got %v", err)
+	}
+	assertWrites(t, f, nil, nil)
+}
+
+func TestDigitalOceanProvider_GetRecords(t *testing.T) {
+	p, _ := fakeDigitalOcean(t, []digitalOceanRecord{
+		{ID: 7, Type: "A", Name: "home", Data: "198.51.100.1"},
+		{ID: 8, Type: "AAAA", Name: "home", Data: "2001:db8::1"},
+	})
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home.example.com", RecordTypeAAAA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "2001:db8::1" {
+		t.Errorf("expected [2001:db8::1], got %v, %v", addrs, err)
+	}
+}
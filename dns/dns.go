@@ -10,24 +10,127 @@ import (
 	"github.com/libdns/libdns"
 )
 
+// Record types managed by the updater.
+const (
+	RecordTypeA    = "A"
+	RecordTypeAAAA = "AAAA"
+)
+
 // Provider defines the interface for DNS operations.
 type Provider interface {
-	UpdateARecord(ctx context.Context, zone, name, ip string, ttl time.Duration) error
+	// UpdateRecord upserts an A or AAAA record with the given address.
+	UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error
+	// GetRecords returns the addresses currently published in the A or
+	// AAAA record with the given name, or none if it does not exist.
+	GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error)
+}
+
+// Record is an address record to publish.
+type Record struct {
+	Name string // record name, e.g. "home.example.com"
+	Type string // RecordTypeA or RecordTypeAAAA
+	IP   string
+	TTL  time.Duration
+}
+
+// BatchProvider is implemented by providers that can upsert several records
+// of a zone with a single request.
+type BatchProvider interface {
+	Provider
+	// UpdateRecords upserts records in zone. It returns one error per
+	// record, nil for each record that was updated.
+	UpdateRecords(ctx context.Context, zone string, records []Record) []error
+}
+
+// applyBatch sends changes with a single call to apply and stores the result
+// in errs at the index of the record each change belongs to. Providers apply
+// a batch atomically, so one change that rejected reports as invalid fails
+// all of them; the changes are then applied one by one to find the culprit.
+func applyBatch[T any](changes []T, indexes []int, errs []error, apply func([]T) error, rejected func(error) bool) []error {
+	err := apply(changes)
+	if len(changes) > 1 && rejected(err) {
+		for j, i := range indexes {
+			errs[i] = apply(changes[j : j+1])
+		}
+		return errs
+	}
+
+	for _, i := range indexes {
+		errs[i] = err
+	}
+	return errs
+}
+
+// AuthoritativeLookup reads records from the authoritative name servers of
+// a zone. It is implemented by propagation.Verifier.
+type AuthoritativeLookup interface {
+	Lookup(ctx context.Context, zone, name, recordType string) ([]string, error)
+}
+
+// LookupProvider is implemented by providers without an API to read
+// records, which read them from the name servers through the lookup set
+// instead.
+type LookupProvider interface {
+	Provider
+	SetLookup(lookup AuthoritativeLookup)
 }
 
 // Config represents the configuration for DNS providers.
 type Config struct {
-	Provider string // "route53" or "cloudflare"
-	
+	Provider string // e.g. "route53" or "cloudflare"; see NewProvider
+
 	// AWS Route53 settings (prefixed with AWS_)
 	AWSAccessKeyID     string
 	AWSSecretAccessKey string
-	AWSRegion         string
-	
+	AWSRegion          string
+	AWSHostedZoneID    string // used instead of looking up the hosted zone by name
+	AWSProfile         string // shared config profile, e.g. an SSO profile
+	// Role to assume, with the credentials above or a web identity token
+	AWSRoleARN              string
+	AWSExternalID           string
+	AWSRoleSessionName      string // defaults to "dns-updater"
+	AWSWebIdentityTokenFile string
+
 	// Cloudflare settings (prefixed with CF_)
 	CFAPIToken string
 	CFEmail    string
 	CFAPIKey   string
+
+	// RFC 2136 settings (prefixed with RFC2136_)
+	RFC2136Server       string // primary name server, host[:port]
+	RFC2136KeyName      string // TSIG key name; unsigned updates if empty
+	RFC2136Secret       string // base64 encoded TSIG secret
+	RFC2136Algorithm    string // "hmac-sha256" (default) or "hmac-sha512"
+	RFC2136Prerequisite string // PrerequisiteNone (default), PrerequisiteRRsetExists or PrerequisiteNameExists
+
+	// PowerDNS settings (prefixed with PDNS_)
+	PDNSAPIURL   string // e.g. "http://127.0.0.1:8081"
+	PDNSServerID string // defaults to "localhost"
+	PDNSAPIKey   string
+
+	// API tokens of the DigitalOcean, Hetzner DNS and Linode APIs
+	DOAPIToken      string
+	HetznerAPIToken string
+	LinodeAPIToken  string
+
+	// Dynamic DNS service settings
+	DynDNSServer   string // DynDNS2 server, e.g. "members.dyndns.org"
+	DynDNSUsername string
+	DynDNSPassword string
+	DuckDNSToken   string
+	Dynv6Token     string
+	DeSECToken     string
+
+	// Google Cloud DNS settings (prefixed with GCP_)
+	GCPProject         string // defaults to the project of the service account key
+	GCPCredentialsFile string // service account key; the metadata server is used if empty
+
+	// Azure DNS settings (prefixed with AZURE_)
+	AzureSubscriptionID string
+	AzureResourceGroup  string
+	AzureTenantID       string
+	AzureClientID       string // service principal, or user-assigned managed identity
+	AzureClientSecret   string // a managed identity is used if empty
 }
 
 // NewProvider creates a new DNS provider based on the configuration.
@@ -37,6 +140,28 @@ func NewProvider(config Config) (Provider, error) {
 		return NewRoute53Provider(config)
 	case "cloudflare":
 		return NewCloudflareProvider(config)
+	case "rfc2136":
+		return NewRFC2136Provider(config)
+	case "powerdns":
+		return NewPowerDNSProvider(config)
+	case "digitalocean":
+		return NewDigitalOceanProvider(config)
+	case "hetzner":
+		return NewHetznerProvider(config)
+	case "linode":
+		return NewLinodeProvider(config)
+	case "dyndns2":
+		return NewDynDNS2Provider(config)
+	case "duckdns":
+		return NewDuckDNSProvider(config)
+	case "dynv6":
+		return NewDynv6Provider(config)
+	case "desec":
+		return NewDeSECProvider(config)
+	case "googleclouddns":
+		return NewGoogleCloudDNSProvider(config)
+	case "azure":
+		return NewAzureProvider(config)
 	default:
 		return nil, fmt.Errorf("unsupported DNS provider: %s", config.Provider)
 	}
@@ -55,52 +180,89 @@ func normalizeName(name, zone string) string {
 	if name == "" || name == "@" {
 		return zone
 	}
-	
+
 	// If name already contains the zone, use as-is
 	if strings.HasSuffix(name, zone) {
 		return name
 	}
-	
+
 	// If name doesn't end with dot, add zone
 	if !strings.HasSuffix(name, ".") {
 		return name + "." + zone
 	}
-	
+
 	return name
 }
 
-// validateIP checks if the provided string is a valid IPv4 address.
-func validateIP(ip string) error {
-	if net.ParseIP(ip) == nil {
+// validateIP checks that ip is a valid address of the family matching recordType.
+func validateIP(ip, recordType string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
 		return fmt.Errorf("invalid IP address: %s", ip)
 	}
+	switch recordType {
+	case RecordTypeA:
+		if parsed.To4() == nil {
+			return fmt.Errorf("A record requires an IPv4 address, got %s", ip)
+		}
+	case RecordTypeAAAA:
+		if parsed.To4() != nil {
+			return fmt.Errorf("AAAA record requires an IPv6 address, got %s", ip)
+		}
+	default:
+		return fmt.Errorf("unsupported record type: %s", recordType)
+	}
 	return nil
 }
 
-// createRecord creates a libdns.Record for an A record.
-func createRecord(name, zone, ip string, ttl time.Duration) (libdns.Record, error) {
-	if err := validateIP(ip); err != nil {
+// createRecord creates a libdns.Record for an A or AAAA record.
+func createRecord(name, zone, recordType, ip string, ttl time.Duration) (libdns.Record, error) {
+	if err := validateIP(ip, recordType); err != nil {
 		return libdns.Record{}, err
 	}
-	
+
+	return libdns.Record{
+		Type:  recordType,
+		Name:  relativeName(name, zone),
+		Value: ip,
+		TTL:   ttl,
+	}, nil
+}
+
+// relativeName returns the name of a record relative to zone, as libdns
+// expects it.
+func relativeName(name, zone string) string {
 	normalizedZone := normalizeZone(zone)
 	recordName := name
-	
-	// For libdns, we need the record name relative to the zone
-	if name == "" || name == "@" {
+
+	if name == "" || name == "@" || strings.EqualFold(normalizeZone(name), normalizedZone) {
 		recordName = "@"
-	} else if strings.HasSuffix(name, "."+normalizedZone) {
+	} else if strings.HasSuffix(normalizeZone(name), "."+normalizedZone) {
 		// Remove the zone suffix to make it relative
-		recordName = strings.TrimSuffix(name, "."+normalizedZone)
+		recordName = strings.TrimSuffix(normalizeZone(name), "."+normalizedZone)
 	} else if !strings.Contains(name, ".") {
 		// Simple name like "home" - use as-is
 		recordName = name
 	}
-	
-	return libdns.Record{
-		Type:  "A",
-		Name:  recordName,
-		Value: ip,
-		TTL:   ttl,
-	}, nil
-}
\ No newline at end of file
+	return recordName
+}
+
+// recordKey identifies the record with the given fully qualified name in
+// the per-record settings of a provider.
+func recordKey(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// absoluteName returns the fully qualified name of a record, with a
+// trailing dot.
+func absoluteName(name, zone string) string {
+	return libdns.AbsoluteName(relativeName(name, zone), normalizeZone(zone))
+}
+
+// validateRecordType checks that recordType is one the updater manages.
+func validateRecordType(recordType string) error {
+	if recordType != RecordTypeA && recordType != RecordTypeAAAA {
+		return fmt.Errorf("unsupported record type: %s", recordType)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package dns
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecordNames(t *testing.T) {
+	tests := []struct {
+		name, zone     string
+		wantRelative   string
+		wantAbsolute   string
+		wantRESTRecord string
+	}{
+		{"home.example.com", "example.com", "home", "home.example.com.", "home"},
+		{"home", "example.com", "home", "home.example.com.", "home"},
+		{"a.b.example.com.", "example.com.", "a.b", "a.b.example.com.", "a.b"},
+		{"example.com", "example.com", "@", "example.com.", ""},
+		{"Example.COM.", "example.com", "@", "example.com.", ""},
+		{"@", "example.com", "@", "example.com.", ""},
+		{"*.example.com", "example.com", "*", "*.example.com.", "*"},
+		{"*.home.example.com", "example.com", "*.home", "*.home.example.com.", "*.home"},
+	}
+	for _, tt := range tests {
+		if got := relativeName(tt.name, tt.zone); got != tt.wantRelative {
+			t.Errorf("relativeName(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.wantRelative)
+		}
+		if got := absoluteName(tt.name, tt.zone); got != tt.wantAbsolute {
+			t.Errorf("absoluteName(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.wantAbsolute)
+		}
+		if got := relativeRecordName(tt.name, tt.zone, ""); got != tt.wantRESTRecord {
+			t.Errorf("relativeRecordName(%q, %q) = %q, want %q", tt.name, tt.zone, got, tt.wantRESTRecord)
+		}
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	errRejected := errors.New("rejected")
+	var calls [][]string
+	apply := func(changes []string) error {
+		calls = append(calls, changes)
+		for _, c := range changes {
+			if c == "bad" {
+				return errRejected
+			}
+		}
+		return nil
+	}
+	rejected := func(err error) bool { return errors.Is(err, errRejected) }
+
+	errs := applyBatch([]string{"a", "bad", "b"}, []int{0, 2, 3}, make([]error, 4), apply, rejected)
+	if errs[0] != nil || errs[2] != errRejected || errs[3] != nil {
+		t.Errorf("expected only the bad change to fail, got %v", errs)
+	}
+	if !reflect.DeepEqual(calls, [][]string{{"a", "bad", "b"}, {"a"}, {"bad"}, {"b"}}) {
+		t.Errorf("expected the batch and then each change, got %v", calls)
+	}
+
+	calls = nil
+	errOther := errors.New("unavailable")
+	errs = applyBatch([]string{"a", "b"}, []int{0, 1}, make([]error, 2), func([]string) error {
+		calls = append(calls, nil)
+		return errOther
+	}, rejected)
+	if errs[0] != errOther || errs[1] != errOther || len(calls) != 1 {
+		t.Errorf("expected the batch error for every record from one call, got %v after %d calls", errs, len(calls))
+	}
+}
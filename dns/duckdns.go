@@ -0,0 +1,71 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// duckDNSUpdateURL is the update endpoint of DuckDNS.
+const duckDNSUpdateURL = "https://www.duckdns.org/update"
+
+// DuckDNSProvider updates records of subdomains of duckdns.org.
+type DuckDNSProvider struct {
+	dynamicDNS
+	updateURL string
+	token     string
+}
+
+// NewDuckDNSProvider creates a new DuckDNS DNS provider.
+func NewDuckDNSProvider(config Config) (*DuckDNSProvider, error) {
+	if config.DuckDNSToken == "" {
+		return nil, fmt.Errorf("DuckDNS provider requires DUCKDNS_TOKEN")
+	}
+
+	return &DuckDNSProvider{
+		dynamicDNS: newDynamicDNS(),
+		updateURL:  duckDNSUpdateURL,
+		token:      config.DuckDNSToken,
+	}, nil
+}
+
+// UpdateRecord updates the A or AAAA record of a DuckDNS subdomain. Names
+// below the subdomain, such as www.home.duckdns.org, share its addresses.
+// The TTL is chosen by DuckDNS.
+func (d *DuckDNSProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+
+	hostname := strings.TrimSuffix(absoluteName(name, zone), ".")
+	prefix, ok := strings.CutSuffix(hostname, ".duckdns.org")
+	if !ok || prefix == "" {
+		return Permanent(fmt.Errorf("%s is not a subdomain of duckdns.org", hostname))
+	}
+	labels := strings.Split(prefix, ".")
+
+	params := url.Values{"domains": {labels[len(labels)-1]}, "token": {d.token}}
+	if recordType == RecordTypeA {
+		params.Set("ip", ip)
+	} else {
+		params.Set("ipv6", ip)
+	}
+	status, body, err := d.get(ctx, d.updateURL+"?"+params.Encode(), "", "")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case status < 200 || status > 299:
+		return &APIError{Provider: "DuckDNS", StatusCode: status, Message: body}
+	case body == "OK":
+		return nil
+	case body == "KO":
+		// DuckDNS gives no reason; it is an unknown token or subdomain.
+		return Permanent(fmt.Errorf("DuckDNS refused the update of %s: invalid token or subdomain", hostname))
+	default:
+		return fmt.Errorf("unexpected DuckDNS response: %q", body)
+	}
+}
@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDuckDNSProvider_UpdateRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordName string
+		recordType string
+		ip         string
+		body       string
+		query      string
+		permanent  bool
+		expectErr  bool
+	}{
+		{
+			name:       "IPv4",
+			recordName: "home.duckdns.org",
+			recordType: RecordTypeA,
+			ip:         "203.0.113.1",
+			body:       "OK",
+			query:      "domains=home&ip=203.0.113.1&token=token",
+		},
+		{
+			name:       "IPv6 below subdomain",
+			recordName: "www.home",
+			recordType: RecordTypeAAAA,
+			ip:         "2001:db8::1",
+			body:       "OK",
+			query:      "domains=home&ipv6=2001%3Adb8%3A%3A1&token=token",
+		},
+		{
+			name:       "refused",
+			recordName: "home.duckdns.org",
+			recordType: RecordTypeA,
+			ip:         "203.0.113.1",
+			body:       "KO",
+			query:      "domains=home&ip=203.0.113.1&token=token",
+			expectErr:  true,
+			permanent:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, last := fakeUpdateServer(t, http.StatusOK, tt.body)
+			p, err := NewDuckDNSProvider(Config{DuckDNSToken: "token"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			p.updateURL = srv.URL + "/update"
+
+			err = p.UpdateRecord(context.Background(), "duckdns.org", tt.recordName, tt.recordType, tt.ip, time.Minute)
+			if (err != nil) != tt.expectErr || IsPermanent(err) != tt.permanent {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (*last).URL.RawQuery != tt.query {
+				t.Errorf("expected query %s, got %s", tt.query, (*last).URL.RawQuery)
+			}
+		})
+	}
+}
+
+func TestDuckDNSProvider_UpdateRecordOtherDomain(t *testing.T) {
+	p, err := NewDuckDNSProvider(Config{DuckDNSToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", time.Minute)
+	if !IsPermanent(err) {
+		t.Errorf("expected permanent error, got %v", err)
+	}
+}
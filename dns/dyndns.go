@@ -0,0 +1,204 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// userAgent identifies the updater to dynamic DNS services, which may block
+// clients sending a generic one.
+const userAgent = "dns-updater"
+
+// dynamicDNS holds what the providers of dynamic DNS services have in
+// common. These services accept updates through a URL but offer no API to
+// read records, so records are read from their name servers instead.
+type dynamicDNS struct {
+	httpClient *http.Client
+	lookup     AuthoritativeLookup
+}
+
+func newDynamicDNS() dynamicDNS {
+	return dynamicDNS{httpClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// SetLookup sets how records are read back from the name servers. Only
+// drift detection needs it; updates work without it.
+func (d *dynamicDNS) SetLookup(lookup AuthoritativeLookup) {
+	d.lookup = lookup
+}
+
+// GetRecords returns the addresses of the record as served by the
+// authoritative name servers of zone.
+func (d *dynamicDNS) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+	if d.lookup == nil {
+		return nil, fmt.Errorf("reading records requires name server lookups, which are not set up")
+	}
+	return d.lookup.Lookup(ctx, zone, absoluteName(name, zone), recordType)
+}
+
+// get requests the update URL and returns the first line of the response
+// body along with the status code.
+func (d *dynamicDNS) get(ctx context.Context, rawURL, username, password string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if err != nil {
+		return 0, "", err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	return resp.StatusCode, strings.TrimSpace(line), nil
+}
+
+// DynDNS2Error is returned when a DynDNS2 server refuses an update.
+type DynDNS2Error struct {
+	Code string // return code, e.g. "badauth" or "911"
+}
+
+func (e *DynDNS2Error) Error() string {
+	if reason, ok := dynDNS2Reasons[e.Code]; ok {
+		return fmt.Sprintf("update refused (%s): %s", e.Code, reason)
+	}
+	return fmt.Sprintf("update refused: %s", e.Code)
+}
+
+// dynDNS2Reasons explains the DynDNS2 return codes that signal an error.
+var dynDNS2Reasons = map[string]string{
+	"badauth":  "invalid username or password",
+	"!donator": "option not available to this account",
+	"notfqdn":  "hostname is not a fully qualified domain name",
+	"nohost":   "hostname does not exist in this account",
+	"numhost":  "too many hosts in one update",
+	"abuse":    "hostname is blocked for abuse",
+	"badagent": "user agent or request rejected",
+	"dnserr":   "DNS error on the server",
+	"911":      "server problem or maintenance",
+}
+
+// dynDNS2RetryAfter is how long the DynDNS2 protocol asks clients to wait
+// after a server-side problem before trying again.
+const dynDNS2RetryAfter = 30 * time.Minute
+
+// parseDynDNS2Response maps the return code at the start of a DynDNS2
+// response to an error. Server-side problems ("dnserr", "911") are worth
+// retrying, but only after dynDNS2RetryAfter; all other errors are
+// permanent. Retrying too early, or at all for errors such as "badauth",
+// gets the account blocked.
+func parseDynDNS2Response(status int, body string) error {
+	code, _, _ := strings.Cut(body, " ")
+	switch code {
+	case "good", "nochg":
+		return nil
+	case "dnserr", "911":
+		return ThrottledFor(&DynDNS2Error{Code: code}, dynDNS2RetryAfter)
+	}
+	if _, ok := dynDNS2Reasons[code]; ok {
+		return Permanent(&DynDNS2Error{Code: code})
+	}
+	if status < 200 || status > 299 {
+		return &APIError{Provider: "DynDNS2", StatusCode: status, Message: body}
+	}
+	return fmt.Errorf("unexpected response: %q", body)
+}
+
+// DynDNS2Provider updates records with the DynDNS2 protocol spoken by many
+// dynamic DNS services.
+type DynDNS2Provider struct {
+	dynamicDNS
+	updateURL string
+	username  string
+	password  string
+	// params returns the query parameters of an update.
+	params func(hostname, recordType, ip string) url.Values
+}
+
+// NewDynDNS2Provider creates a new DynDNS2 DNS provider sending updates to
+// the /nic/update endpoint of the configured server.
+func NewDynDNS2Provider(config Config) (*DynDNS2Provider, error) {
+	if config.DynDNSServer == "" {
+		return nil, fmt.Errorf("DynDNS2 provider requires DYNDNS_SERVER")
+	}
+	if config.DynDNSUsername == "" || config.DynDNSPassword == "" {
+		return nil, fmt.Errorf("DynDNS2 provider requires DYNDNS_USERNAME and DYNDNS_PASSWORD")
+	}
+
+	server := config.DynDNSServer
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return &DynDNS2Provider{
+		dynamicDNS: newDynamicDNS(),
+		updateURL:  strings.TrimSuffix(server, "/") + "/nic/update",
+		username:   config.DynDNSUsername,
+		password:   config.DynDNSPassword,
+		params: func(hostname, recordType, ip string) url.Values {
+			return url.Values{"hostname": {hostname}, "myip": {ip}}
+		},
+	}, nil
+}
+
+// deSECUpdateURL is the DynDNS2 endpoint of deSEC.
+const deSECUpdateURL = "https://update.dedyn.io/"
+
+// NewDeSECProvider creates a DynDNS2 provider for deSEC. The address of
+// the other family is preserved, since deSEC would otherwise remove it.
+func NewDeSECProvider(config Config) (*DynDNS2Provider, error) {
+	if config.DeSECToken == "" {
+		return nil, fmt.Errorf("deSEC provider requires DESEC_TOKEN")
+	}
+
+	return &DynDNS2Provider{
+		dynamicDNS: newDynamicDNS(),
+		updateURL:  deSECUpdateURL,
+		password:   config.DeSECToken,
+		params: func(hostname, recordType, ip string) url.Values {
+			params := url.Values{"hostname": {hostname}, "myipv4": {"preserve"}, "myipv6": {"preserve"}}
+			if recordType == RecordTypeA {
+				params.Set("myipv4", ip)
+			} else {
+				params.Set("myipv6", ip)
+			}
+			return params
+		},
+	}, nil
+}
+
+// UpdateRecord updates an A or AAAA record using the DynDNS2 provider. The
+// TTL is chosen by the service.
+func (d *DynDNS2Provider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+	hostname := strings.TrimSuffix(absoluteName(name, zone), ".")
+
+	username := d.username
+	if username == "" {
+		// deSEC authenticates with the hostname and the token.
+		username = hostname
+	}
+	status, body, err := d.get(ctx, d.updateURL+"?"+d.params(hostname, recordType, ip).Encode(), username, d.password)
+	if err != nil {
+		return err
+	}
+	return parseDynDNS2Response(status, body)
+}
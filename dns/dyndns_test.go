@@ -0,0 +1,178 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// fakeLookup is an authoritativeLookup returning fixed addresses.
+type fakeLookup struct {
+	addrs []string
+	names []string
+}
+
+func (f *fakeLookup) Lookup(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	f.names = append(f.names, zone+" "+name+" "+recordType)
+	return f.addrs, nil
+}
+
+// fakeUpdateServer answers update requests with a fixed status and body and
+// records the last request.
+func fakeUpdateServer(t *testing.T, status int, body string) (*httptest.Server, **http.Request) {
+	t.Helper()
+	var last *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &last
+}
+
+func TestDynDNS2Provider_UpdateRecord(t *testing.T) {
+	srv, last := fakeUpdateServer(t, http.StatusOK, "good 203.0.113.1\n")
+	p, err := NewDynDNS2Provider(Config{DynDNSServer: srv.URL, DynDNSUsername: "user", DynDNSPassword: "pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := *last
+	if r.URL.Path != "/nic/update" {
+		t.Errorf("expected /nic/update, got %s", r.URL.Path)
+	}
+	if q := r.URL.Query(); q.Get("hostname") != "home.example.com" || q.Get("myip") != "203.0.113.1" {
+		t.Errorf("unexpected query %v", q)
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("expected basic auth, got %q %q", user, pass)
+	}
+	if r.UserAgent() != userAgent {
+		t.Errorf("expected user agent %q, got %q", userAgent, r.UserAgent())
+	}
+}
+
+func TestParseDynDNS2Response(t *testing.T) {
+	tests := []struct {
+		status     int
+		body       string
+		expectErr  bool
+		permanent  bool
+		retryAfter time.Duration
+	}{
+		{status: 200, body: "good 203.0.113.1"},
+		{status: 200, body: "nochg 203.0.113.1"},
+		{status: 401, body: "badauth", expectErr: true, permanent: true},
+		{status: 200, body: "nohost", expectErr: true, permanent: true},
+		{status: 200, body: "notfqdn", expectErr: true, permanent: true},
+		{status: 200, body: "abuse", expectErr: true, permanent: true},
+		{status: 200, body: "!donator", expectErr: true, permanent: true},
+		{status: 200, body: "911", expectErr: true, retryAfter: 30 * time.Minute},
+		{status: 200, body: "dnserr", expectErr: true, retryAfter: 30 * time.Minute},
+		{status: 502, body: "Bad Gateway", expectErr: true},
+		{status: 200, body: "<html>", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			err := parseDynDNS2Response(tt.status, tt.body)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error=%v, got %v", tt.expectErr, err)
+			}
+			if IsPermanent(err) != tt.permanent {
+				t.Errorf("expected permanent=%v, got %v", tt.permanent, err)
+			}
+			if d := RetryAfter(err); d != tt.retryAfter {
+				t.Errorf("expected retry after %s, got %s", tt.retryAfter, d)
+			}
+		})
+	}
+
+	var dynErr *DynDNS2Error
+	if err := parseDynDNS2Response(200, "abuse"); !errors.As(err, &dynErr) || dynErr.Code != "abuse" {
+		t.Errorf("expected DynDNS2Error with code abuse, got %v", err)
+	}
+}
+
+func TestDeSECProvider_UpdateRecord(t *testing.T) {
+	srv, last := fakeUpdateServer(t, http.StatusOK, "good")
+	p, err := NewDeSECProvider(Config{DeSECToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.updateURL = srv.URL + "/"
+
+	if err := p.UpdateRecord(context.Background(), "dedyn.io", "home.dedyn.io", RecordTypeAAAA, "2001:db8::1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := *last
+	expected := url.Values{"hostname": {"home.dedyn.io"}, "myipv4": {"preserve"}, "myipv6": {"2001:db8::1"}}
+	if r.URL.RawQuery != expected.Encode() {
+		t.Errorf("expected query %s, got %s", expected.Encode(), r.URL.RawQuery)
+	}
+	if user, pass, _ := r.BasicAuth(); user != "home.dedyn.io" || pass != "token" {
+		t.Errorf("expected hostname and token as credentials, got %q %q", user, pass)
+	}
+}
+
+func TestDynamicDNS_GetRecords(t *testing.T) {
+	lookup := &fakeLookup{addrs: []string{"203.0.113.1"}}
+	p := &DuckDNSProvider{dynamicDNS: dynamicDNS{lookup: lookup}}
+
+	addrs, err := p.GetRecords(context.Background(), "duckdns.org", "home", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "203.0.113.1" {
+		t.Errorf("expected [203.0.113.1], got %v, %v", addrs, err)
+	}
+	if len(lookup.names) != 1 || lookup.names[0] != "duckdns.org home.duckdns.org. A" {
+		t.Errorf("unexpected lookups %v", lookup.names)
+	}
+
+	if _, err := p.GetRecords(context.Background(), "duckdns.org", "home", "MX"); !IsPermanent(err) {
+		t.Errorf("expected permanent error for unsupported type, got %v", err)
+	}
+}
+
+func TestDynamicDNS_SetLookup(t *testing.T) {
+	provider, err := NewProvider(Config{Provider: "duckdns", DuckDNSToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, ok := provider.(LookupProvider)
+	if !ok {
+		t.Fatalf("expected %T to implement LookupProvider", provider)
+	}
+
+	if _, err := p.GetRecords(context.Background(), "duckdns.org", "home", RecordTypeA); err == nil {
+		t.Error("expected error without a lookup, got nil")
+	}
+
+	p.SetLookup(&fakeLookup{addrs: []string{"203.0.113.1"}})
+	addrs, err := p.GetRecords(context.Background(), "duckdns.org", "home", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "203.0.113.1" {
+		t.Errorf("expected [203.0.113.1], got %v, %v", addrs, err)
+	}
+}
+
+func TestNewDynDNS2Provider(t *testing.T) {
+	p, err := NewDynDNS2Provider(Config{DynDNSServer: "members.dyndns.org", DynDNSUsername: "user", DynDNSPassword: "pass"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.updateURL != "https://members.dyndns.org/nic/update" {
+		t.Errorf("unexpected update URL %s", p.updateURL)
+	}
+
+	if _, err := NewDynDNS2Provider(Config{DynDNSServer: "members.dyndns.org"}); err == nil {
+		t.Error("expected error without credentials, got nil")
+	}
+}
@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dynv6UpdateURL is the update endpoint of dynv6.
+const dynv6UpdateURL = "https://dynv6.com/api/update"
+
+// Dynv6Provider updates the addresses of dynv6 zones.
+type Dynv6Provider struct {
+	dynamicDNS
+	updateURL string
+	token     string
+}
+
+// NewDynv6Provider creates a new dynv6 DNS provider.
+func NewDynv6Provider(config Config) (*Dynv6Provider, error) {
+	if config.Dynv6Token == "" {
+		return nil, fmt.Errorf("dynv6 provider requires DYNV6_TOKEN")
+	}
+
+	return &Dynv6Provider{
+		dynamicDNS: newDynamicDNS(),
+		updateURL:  dynv6UpdateURL,
+		token:      config.Dynv6Token,
+	}, nil
+}
+
+// UpdateRecord updates the A or AAAA record of a dynv6 zone, such as
+// home.dynv6.net. The TTL is chosen by dynv6.
+func (d *Dynv6Provider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+	hostname := strings.TrimSuffix(absoluteName(name, zone), ".")
+
+	params := url.Values{"hostname": {hostname}, "token": {d.token}}
+	if recordType == RecordTypeA {
+		params.Set("ipv4", ip)
+	} else {
+		params.Set("ipv6", ip)
+	}
+	status, body, err := d.get(ctx, d.updateURL+"?"+params.Encode(), "", "")
+	if err != nil {
+		return err
+	}
+	// dynv6 answers "addresses updated" or "addresses unchanged", and
+	// signals errors such as an invalid token or unknown zone by status.
+	if status < 200 || status > 299 {
+		return &APIError{Provider: "dynv6", StatusCode: status, Message: body}
+	}
+	return nil
+}
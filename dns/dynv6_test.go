@@ -0,0 +1,40 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDynv6Provider_UpdateRecord(t *testing.T) {
+	srv, last := fakeUpdateServer(t, http.StatusOK, "addresses updated")
+	p, err := NewDynv6Provider(Config{Dynv6Token: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.updateURL = srv.URL + "/api/update"
+
+	if err := p.UpdateRecord(context.Background(), "dynv6.net", "home.dynv6.net", RecordTypeAAAA, "2001:db8::1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q := (*last).URL.RawQuery; q != "hostname=home.dynv6.net&ipv6=2001%3Adb8%3A%3A1&token=token" {
+		t.Errorf("unexpected query %s", q)
+	}
+}
+
+func TestDynv6Provider_UpdateRecordUnauthorized(t *testing.T) {
+	srv, _ := fakeUpdateServer(t, http.StatusUnauthorized, "invalid authentication token")
+	p, err := NewDynv6Provider(Config{Dynv6Token: "wrong"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.updateURL = srv.URL
+
+	err = p.UpdateRecord(context.Background(), "dynv6.net", "home.dynv6.net", RecordTypeA, "203.0.113.1", time.Minute)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "invalid authentication token" {
+		t.Errorf("expected HTTP 401, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"errors"
+	"time"
+)
+
+// PermanentError marks a provider error that retrying will not fix, such as
+// rejected credentials or a zone that does not exist.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err in a PermanentError. A nil error is returned as is.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err or any error it wraps is a PermanentError.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// ThrottledError marks a provider error caused by rate limiting, which is
+// worth retrying even when the provider reports it with a client error
+// status.
+type ThrottledError struct {
+	Err error
+	// RetryAfter is how long the provider asks to wait before the next
+	// attempt, or zero if it does not say.
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// Throttled wraps err in a ThrottledError. A nil error is returned as is.
+func Throttled(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ThrottledError{Err: err}
+}
+
+// ThrottledFor wraps err in a ThrottledError asking to wait d before the
+// next attempt. A nil error is returned as is.
+func ThrottledFor(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &ThrottledError{Err: err, RetryAfter: d}
+}
+
+// RetryAfter returns how long the provider asked to wait before retrying
+// after err, or zero if err is not a ThrottledError asking for a wait.
+func RetryAfter(err error) time.Duration {
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		return throttled.RetryAfter
+	}
+	return 0
+}
+
+// IsThrottled reports whether err or any error it wraps is a ThrottledError.
+func IsThrottled(err error) bool {
+	var throttled *ThrottledError
+	return errors.As(err, &throttled)
+}
+
+// isClientError reports whether an HTTP status code signals a problem with
+// the request itself rather than with the server. Rate limiting is not one.
+func isClientError(code int) bool {
+	return code >= 400 && code < 500 && code != 429
+}
+
+// route53PermanentCodes are AWS error codes that retrying will not fix.
+var route53PermanentCodes = []string{
+	"AccessDenied",
+	"ExpiredToken",
+	"HostedZoneNotFound",
+	"IncompleteSignature",
+	"InvalidChangeBatch",
+	"InvalidClientTokenId",
+	"InvalidDomainName",
+	"InvalidInput",
+	"NoSuchHostedZone",
+	"SignatureDoesNotMatch",
+	"UnrecognizedClientException",
+}
+
+// route53ThrottlingCodes are AWS error codes for throttling, which Route53
+// reports with a 400 status.
+var route53ThrottlingCodes = []string{
+	"PriorRequestNotComplete",
+	"Throttling",
+	"ThrottlingException",
+}
+
+// classifyRoute53Error marks throttling as such, and authentication
+// failures, missing zones, invalid input and other client errors as
+// permanent.
+func classifyRoute53Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		for _, code := range route53ThrottlingCodes {
+			if apiErr.ErrorCode() == code {
+				return Throttled(err)
+			}
+		}
+		for _, code := range route53PermanentCodes {
+			if apiErr.ErrorCode() == code {
+				return Permanent(err)
+			}
+		}
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) && isClientError(statusErr.HTTPStatusCode()) {
+		return Permanent(err)
+	}
+	return err
+}
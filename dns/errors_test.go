@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type apiError struct {
+	code   string
+	status int
+}
+
+func (e apiError) Error() string       { return e.code }
+func (e apiError) ErrorCode() string   { return e.code }
+func (e apiError) HTTPStatusCode() int { return e.status }
+
+func TestClassifyRoute53Error(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+		throttled bool
+	}{
+		{name: "invalid credentials", err: fmt.Errorf("operation error: %w", apiError{"InvalidClientTokenId", 403}), permanent: true},
+		{name: "throttled", err: fmt.Errorf("operation error: %w", apiError{"Throttling", 400}), throttled: true},
+		{name: "bad request", err: fmt.Errorf("operation error: %w", apiError{"Unknown", 400}), permanent: true},
+		{name: "service unavailable", err: fmt.Errorf("operation error: %w", apiError{"ServiceUnavailable", 503})},
+		{name: "network error", err: errors.New("dial tcp: i/o timeout")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyRoute53Error(tt.err)
+			if got := IsPermanent(err); got != tt.permanent {
+				t.Errorf("expected permanent=%v, got %v", tt.permanent, got)
+			}
+			if got := IsThrottled(err); got != tt.throttled {
+				t.Errorf("expected throttled=%v, got %v", tt.throttled, got)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	base := errors.New("busy")
+	if d := RetryAfter(fmt.Errorf("update: %w", ThrottledFor(base, time.Minute))); d != time.Minute {
+		t.Errorf("expected a minute, got %s", d)
+	}
+	if d := RetryAfter(Throttled(base)); d != 0 {
+		t.Errorf("expected no wait without a retry-after, got %s", d)
+	}
+	if d := RetryAfter(base); d != 0 {
+		t.Errorf("expected no wait for an unthrottled error, got %s", d)
+	}
+	if ThrottledFor(nil, time.Minute) != nil {
+		t.Error("expected nil for nil error")
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	if Permanent(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+	base := errors.New("boom")
+	err := fmt.Errorf("update: %w", Permanent(base))
+	if !IsPermanent(err) {
+		t.Error("expected wrapped permanent error to be detected")
+	}
+	if !errors.Is(err, base) {
+		t.Error("expected permanent error to unwrap to its cause")
+	}
+}
@@ -0,0 +1,310 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// googleCloudDNSBaseURL is the Cloud DNS API v1 endpoint.
+	googleCloudDNSBaseURL = "https://dns.googleapis.com/dns/v1/projects/"
+	// googleCloudDNSScope is the OAuth scope granting access to Cloud DNS.
+	googleCloudDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+	// googleMetadataTokenURL is the token endpoint of the metadata server,
+	// which serves the tokens of the attached service account on Compute
+	// Engine and of the workload identity on GKE.
+	googleMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GoogleCloudDNSProvider updates records through the Google Cloud DNS API.
+type GoogleCloudDNSProvider struct {
+	client *restClient
+
+	mu           sync.Mutex
+	managedZones map[string]string // zone name to managed zone name
+}
+
+// NewGoogleCloudDNSProvider creates a new Google Cloud DNS provider. It
+// authenticates with the service account key in GCP_CREDENTIALS_FILE, or
+// with the metadata server if no key is configured.
+func NewGoogleCloudDNSProvider(config Config) (*GoogleCloudDNSProvider, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	project := config.GCPProject
+
+	var token *accessToken
+	if config.GCPCredentialsFile != "" {
+		key, err := loadGoogleServiceAccount(config.GCPCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		if project == "" {
+			project = key.ProjectID
+		}
+		token = newAccessToken(key.tokenFetcher(httpClient))
+	} else {
+		token = newAccessToken(googleMetadataToken(httpClient, googleMetadataTokenURL))
+	}
+	if project == "" {
+		return nil, fmt.Errorf("Google Cloud DNS provider requires GCP_PROJECT")
+	}
+
+	client := newRESTClient("Google Cloud DNS", googleCloudDNSBaseURL+url.PathEscape(project), nil, googleErrorMessage)
+	client.authorize = token.authorize
+	return &GoogleCloudDNSProvider{
+		client:       client,
+		managedZones: make(map[string]string),
+	}, nil
+}
+
+// googleErrorMessage extracts the message of an error response.
+func googleErrorMessage(body []byte) string {
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	return resp.Error.Message
+}
+
+// googleServiceAccount holds the fields of a service account key file used
+// to obtain access tokens.
+type googleServiceAccount struct {
+	Type        string `json:"type"`
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// loadGoogleServiceAccount reads a service account key file.
+func loadGoogleServiceAccount(path string) (*googleServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCP credentials: %w", err)
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid GCP credentials file %s: %w", path, err)
+	}
+	if account.Type != "service_account" {
+		return nil, fmt.Errorf("invalid GCP credentials file %s: expected a service account key, got type %q", path, account.Type)
+	}
+	if account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, fmt.Errorf("invalid GCP credentials file %s: missing client_email or token_uri", path)
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid GCP credentials file %s: private key is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("invalid GCP credentials file %s: private key is not an RSA key", path)
+	}
+	account.key = key
+	return &account, nil
+}
+
+// assertion returns a JWT signed with the key of the service account, which
+// the token endpoint exchanges for an access token.
+func (a *googleServiceAccount) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   a.ClientEmail,
+		"scope": googleCloudDNSScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// tokenFetcher returns a function obtaining access tokens for the service
+// account.
+func (a *googleServiceAccount) tokenFetcher(httpClient *http.Client) func(ctx context.Context) (string, time.Duration, error) {
+	return func(ctx context.Context) (string, time.Duration, error) {
+		assertion, err := a.assertion(time.Now())
+		if err != nil {
+			return "", 0, err
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return requestToken(httpClient, req, "Google Cloud")
+	}
+}
+
+// googleMetadataToken returns a function obtaining access tokens from the
+// metadata server.
+func googleMetadataToken(httpClient *http.Client, tokenURL string) func(ctx context.Context) (string, time.Duration, error) {
+	return func(ctx context.Context) (string, time.Duration, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL+"?"+url.Values{"scopes": {googleCloudDNSScope}}.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return requestToken(httpClient, req, "Google Cloud")
+	}
+}
+
+// googleRRset is a resource record set as represented by the Cloud DNS API.
+type googleRRset struct {
+	Name    string   `json:"name"` // fully qualified, with a trailing dot
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	RRDatas []string `json:"rrdatas"`
+}
+
+// googleChange is the body of a change request. Cloud DNS applies the
+// deletions and additions of a change atomically.
+type googleChange struct {
+	Additions []googleRRset `json:"additions,omitempty"`
+	Deletions []googleRRset `json:"deletions,omitempty"`
+}
+
+// UpdateRecord updates an A or AAAA record using the Google Cloud DNS
+// provider. The existing record set, if any, is replaced in one change.
+func (g *GoogleCloudDNSProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+
+	managedZone, err := g.managedZone(ctx, zone)
+	if err != nil {
+		return err
+	}
+	rrset := googleRRset{
+		Name:    absoluteName(name, zone),
+		Type:    recordType,
+		TTL:     int(ttl.Seconds()),
+		RRDatas: []string{ip},
+	}
+
+	// Deletions must match the current record set exactly.
+	existing, err := g.findRRset(ctx, managedZone, rrset.Name, recordType)
+	if err != nil {
+		return err
+	}
+	change := googleChange{Additions: []googleRRset{rrset}}
+	if existing != nil {
+		if existing.TTL == rrset.TTL && len(existing.RRDatas) == 1 && existing.RRDatas[0] == ip {
+			return nil
+		}
+		change.Deletions = []googleRRset{*existing}
+	}
+
+	return g.client.do(ctx, http.MethodPost, "/managedZones/"+url.PathEscape(managedZone)+"/changes", change, nil)
+}
+
+// GetRecords returns the addresses of the record set with the given name and
+// type.
+func (g *GoogleCloudDNSProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	managedZone, err := g.managedZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := g.findRRset(ctx, managedZone, absoluteName(name, zone), recordType)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	return existing.RRDatas, nil
+}
+
+// findRRset returns the record set with the given name and type, or nil if
+// there is none.
+func (g *GoogleCloudDNSProvider) findRRset(ctx context.Context, managedZone, fqdn, recordType string) (*googleRRset, error) {
+	query := url.Values{"name": {fqdn}, "type": {recordType}}
+	var resp struct {
+		RRsets []googleRRset `json:"rrsets"`
+	}
+	if err := g.client.do(ctx, http.MethodGet, "/managedZones/"+url.PathEscape(managedZone)+"/rrsets?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	for _, rrset := range resp.RRsets {
+		if strings.EqualFold(rrset.Name, fqdn) && rrset.Type == recordType {
+			return &rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// managedZone returns the name of the managed zone serving zone. Public
+// zones are preferred over private zones of the same name.
+func (g *GoogleCloudDNSProvider) managedZone(ctx context.Context, zone string) (string, error) {
+	dnsName := normalizeZone(zone)
+
+	g.mu.Lock()
+	name, ok := g.managedZones[dnsName]
+	g.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+
+	var resp struct {
+		ManagedZones []struct {
+			Name       string `json:"name"`
+			DNSName    string `json:"dnsName"`
+			Visibility string `json:"visibility"`
+		} `json:"managedZones"`
+	}
+	if err := g.client.do(ctx, http.MethodGet, "/managedZones?"+url.Values{"dnsName": {dnsName}}.Encode(), nil, &resp); err != nil {
+		return "", err
+	}
+	for _, z := range resp.ManagedZones {
+		if !strings.EqualFold(z.DNSName, dnsName) {
+			continue
+		}
+		if name == "" || z.Visibility != "private" {
+			name = z.Name
+		}
+		if z.Visibility != "private" {
+			break
+		}
+	}
+	if name == "" {
+		return "", Permanent(fmt.Errorf("managed zone for %s not found", zone))
+	}
+
+	g.mu.Lock()
+	g.managedZones[dnsName] = name
+	g.mu.Unlock()
+	return name, nil
+}
@@ -0,0 +1,221 @@
+package dns
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGoogleTokenServer exchanges assertions signed with key for the token
+// "token".
+func fakeGoogleTokenServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "Invalid JWT Signature."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeGoogleServiceAccount writes a service account key file for key.
+func writeGoogleServiceAccount(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "dns-updater@my-project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+// fakeGoogleCloudDNS serves a private and a public managed zone for
+// example.com, the public one containing rrsets.
+func fakeGoogleCloudDNS(t *testing.T, rrsets []googleRRset) (*GoogleCloudDNSProvider, *fakeAPI) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokenServer := fakeGoogleTokenServer(t, key)
+	p, err := NewGoogleCloudDNSProvider(Config{GCPCredentialsFile: writeGoogleServiceAccount(t, key, tokenServer.URL)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(p.client.baseURL, "/projects/my-project") {
+		t.Errorf("expected project from credentials, got %s", p.client.baseURL)
+	}
+
+	f := &fakeAPI{handle: func(r *http.Request) (int, interface{}) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			return http.StatusUnauthorized, map[string]interface{}{"error": map[string]interface{}{"code": 401, "message": "Invalid Credentials"}}
+		}
+		switch {
+		case r.URL.Path == "/managedZones":
+			var zones []map[string]string
+			if r.URL.Query().Get("dnsName") == "example.com." {
+				zones = []map[string]string{
+					{"name": "internal", "dnsName": "example.com.", "visibility": "private"},
+					{"name": "example-com", "dnsName": "example.com.", "visibility": "public"},
+				}
+			}
+			return http.StatusOK, map[string]interface{}{"managedZones": zones}
+		case r.URL.Path == "/managedZones/example-com/rrsets":
+			var found []googleRRset
+			for _, rrset := range rrsets {
+				if rrset.Name == r.URL.Query().Get("name") && rrset.Type == r.URL.Query().Get("type") {
+					found = append(found, rrset)
+				}
+			}
+			return http.StatusOK, map[string]interface{}{"rrsets": found}
+		default:
+			return http.StatusOK, map[string]string{"id": "1", "status": "pending"}
+		}
+	}}
+	f.start(t, p.client)
+	return p, f
+}
+
+func TestGoogleCloudDNSProvider_UpdateRecord(t *testing.T) {
+	addition := map[string]interface{}{"name": "home.example.com.", "type": "A", "ttl": float64(60), "rrdatas": []interface{}{"203.0.113.1"}}
+	tests := []struct {
+		name   string
+		rrsets []googleRRset
+		calls  []string
+		bodies []map[string]interface{}
+	}{
+		{
+			name:   "create",
+			calls:  []string{"POST /managedZones/example-com/changes"},
+			bodies: []map[string]interface{}{{"additions": []interface{}{addition}}},
+		},
+		{
+			name:   "replace",
+			rrsets: []googleRRset{{Name: "home.example.com.", Type: "A", TTL: 300, RRDatas: []string{"198.51.100.1", "198.51.100.2"}}},
+			calls:  []string{"POST /managedZones/example-com/changes"},
+			bodies: []map[string]interface{}{{
+				"additions": []interface{}{addition},
+				"deletions": []interface{}{map[string]interface{}{"name": "home.example.com.", "type": "A", "ttl": float64(300), "rrdatas": []interface{}{"198.51.100.1", "198.51.100.2"}}},
+			}},
+		},
+		{
+			name:   "unchanged",
+			rrsets: []googleRRset{{Name: "home.example.com.", Type: "A", TTL: 60, RRDatas: []string{"203.0.113.1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, f := fakeGoogleCloudDNS(t, tt.rrsets)
+
+			if err := p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertWrites(t, f, tt.calls, tt.bodies)
+		})
+	}
+}
+
+func TestGoogleCloudDNSProvider_UpdateRecordErrors(t *testing.T) {
+	p, f := fakeGoogleCloudDNS(t, nil)
+
+	err := p.UpdateRecord(context.Background(), "example.org", "home", RecordTypeA, "203.0.113.1", time.Minute)
+	if !IsPermanent(err) || !strings.Contains(err.Error(), "managed zone for example.org not found") {
+		t.Errorf("expected permanent error for unknown zone, got %v", err)
+	}
+	assertWrites(t, f, nil, nil)
+
+	// The managed zone and the token are cached.
+	p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	lookups := 0
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, "GET /managedZones?dnsName=example.com.") {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected managed zone lookup to be cached, got %d lookups", lookups)
+	}
+}
+
+func TestGoogleCloudDNSProvider_GetRecords(t *testing.T) {
+	p, _ := fakeGoogleCloudDNS(t, []googleRRset{
+		{Name: "home.example.com.", Type: "AAAA", TTL: 60, RRDatas: []string{"2001:db8::1"}},
+	})
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home.example.com", RecordTypeAAAA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "2001:db8::1" {
+		t.Errorf("expected [2001:db8::1], got %v, %v", addrs, err)
+	}
+	addrs, err = p.GetRecords(context.Background(), "example.com", "home.example.com", RecordTypeA)
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("expected no addresses, got %v, %v", addrs, err)
+	}
+}
+
+func TestNewGoogleCloudDNSProvider(t *testing.T) {
+	if _, err := NewGoogleCloudDNSProvider(Config{}); err == nil {
+		t.Error("expected error without project for the metadata server")
+	}
+	if _, err := NewGoogleCloudDNSProvider(Config{GCPProject: "my-project"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(path, []byte(`{"type": "external_account"}`), 0o600)
+	if _, err := NewGoogleCloudDNSProvider(Config{GCPCredentialsFile: path}); err == nil || !strings.Contains(err.Error(), "expected a service account key") {
+		t.Errorf("expected error for non service account credentials, got %v", err)
+	}
+}
+
+func TestGoogleMetadataToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3599, "token_type": "Bearer"})
+	}))
+	defer srv.Close()
+
+	token, lifetime, err := googleMetadataToken(srv.Client(), srv.URL)(context.Background())
+	if err != nil || token != "token" || lifetime != 3599*time.Second {
+		t.Errorf("expected token valid for 3599s, got %q, %v, %v", token, lifetime, err)
+	}
+}
@@ -0,0 +1,190 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hetznerBaseURL is the Hetzner DNS API v1 endpoint.
+const hetznerBaseURL = "https://dns.hetzner.com/api/v1"
+
+// HetznerProvider updates records through the Hetzner DNS API.
+type HetznerProvider struct {
+	client *restClient
+
+	mu      sync.Mutex
+	zoneIDs map[string]string
+}
+
+// NewHetznerProvider creates a new Hetzner DNS provider.
+func NewHetznerProvider(config Config) (*HetznerProvider, error) {
+	if config.HetznerAPIToken == "" {
+		return nil, fmt.Errorf("Hetzner provider requires HETZNER_API_TOKEN")
+	}
+
+	header := http.Header{"Auth-API-Token": {config.HetznerAPIToken}}
+	return &HetznerProvider{
+		client:  newRESTClient("Hetzner", hetznerBaseURL, header, hetznerErrorMessage),
+		zoneIDs: make(map[string]string),
+	}, nil
+}
+
+// hetznerErrorMessage extracts the message of an error response, which
+// comes in different shapes depending on the endpoint.
+func hetznerErrorMessage(body []byte) string {
+	var resp struct {
+		Message string `json:"message"`
+		Error   struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	if resp.Error.Message != "" {
+		return resp.Error.Message
+	}
+	return resp.Message
+}
+
+// hetznerRecord is a DNS record as represented by the Hetzner DNS API.
+type hetznerRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"` // relative to the zone, "@" for the apex
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl"`
+}
+
+// UpdateRecord updates an A or AAAA record using the Hetzner provider. An
+// existing record is updated in place; a missing one is created.
+func (h *HetznerProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+
+	zoneID, err := h.zoneID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	record := hetznerRecord{
+		ZoneID: zoneID,
+		Type:   recordType,
+		Name:   relativeRecordName(name, zone, "@"),
+		Value:  ip,
+		TTL:    int(ttl.Seconds()),
+	}
+
+	existing, err := h.findRecords(ctx, zoneID, record.Name, recordType)
+	if err != nil {
+		return err
+	}
+	match := pickMatch(existing, ip,
+		func(r hetznerRecord) string { return r.Value },
+		func(a, b hetznerRecord) bool { return a.ID < b.ID })
+
+	if match != nil {
+		return h.client.do(ctx, http.MethodPut, "/records/"+url.PathEscape(match.ID), record, nil)
+	}
+	return h.client.do(ctx, http.MethodPost, "/records", record, nil)
+}
+
+// GetRecords returns the contents of the records with the given name and
+// type.
+func (h *HetznerProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	zoneID, err := h.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := h.findRecords(ctx, zoneID, relativeRecordName(name, zone, "@"), recordType)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, rec := range existing {
+		addrs = append(addrs, rec.Value)
+	}
+	return addrs, nil
+}
+
+// findRecords returns the records of the zone with the given relative name
+// and type.
+func (h *HetznerProvider) findRecords(ctx context.Context, zoneID, name, recordType string) ([]hetznerRecord, error) {
+	var records []hetznerRecord
+	for page := 1; ; page++ {
+		query := url.Values{
+			"zone_id":  {zoneID},
+			"page":     {fmt.Sprint(page)},
+			"per_page": {"100"},
+		}
+		var resp struct {
+			Records []hetznerRecord `json:"records"`
+			Meta    struct {
+				Pagination struct {
+					LastPage int `json:"last_page"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		if err := h.client.do(ctx, http.MethodGet, "/records?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, rec := range resp.Records {
+			if rec.Type == recordType && strings.EqualFold(rec.Name, name) {
+				records = append(records, rec)
+			}
+		}
+		if page >= resp.Meta.Pagination.LastPage {
+			return records, nil
+		}
+	}
+}
+
+// zoneID returns the ID of the zone named zone.
+func (h *HetznerProvider) zoneID(ctx context.Context, zone string) (string, error) {
+	zone = strings.TrimSuffix(zone, ".")
+
+	h.mu.Lock()
+	id, ok := h.zoneIDs[zone]
+	h.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var resp struct {
+		Zones []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"zones"`
+	}
+	err := h.client.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {zone}}.Encode(), nil, &resp)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return "", Permanent(fmt.Errorf("zone %s not found", zone))
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, z := range resp.Zones {
+		if strings.EqualFold(z.Name, zone) {
+			id = z.ID
+		}
+	}
+	if id == "" {
+		return "", Permanent(fmt.Errorf("zone %s not found", zone))
+	}
+
+	h.mu.Lock()
+	h.zoneIDs[zone] = id
+	h.mu.Unlock()
+	return id, nil
+}
@@ -0,0 +1,120 @@
+package dns
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHetzner serves zone example.com with ID "zone1", containing records.
+func fakeHetzner(t *testing.T, records []hetznerRecord) (*HetznerProvider, *fakeAPI) {
+	t.Helper()
+	p, err := NewHetznerProvider(Config{HetznerAPIToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := &fakeAPI{handle: func(r *http.Request) (int, interface{}) {
+		if r.Header.Get("Auth-API-Token") != "token" {
+			return http.StatusUnauthorized, map[string]string{"message": "Invalid authentication credentials"}
+		}
+		switch {
+		case r.URL.Path == "/zones":
+			if r.URL.Query().Get("name") != "example.com" {
+				return http.StatusNotFound, map[string]interface{}{"error": map[string]interface{}{"message": "zone not found", "code": 404}}
+			}
+			return http.StatusOK, map[string]interface{}{"zones": []map[string]string{{"id": "zone1", "name": "example.com"}}}
+		case r.URL.Path == "/records" && r.Method == http.MethodGet:
+			return http.StatusOK, map[string]interface{}{
+				"records": records,
+				"meta":    map[string]interface{}{"pagination": map[string]int{"page": 1, "last_page": 1}},
+			}
+		default:
+			return http.StatusOK, map[string]interface{}{"record": map[string]string{"id": "new"}}
+		}
+	}}
+	f.start(t, p.client)
+	return p, f
+}
+
+func TestHetznerProvider_UpdateRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []hetznerRecord
+		calls   []string
+	}{
+		{
+			name:  "create",
+			calls: []string{"POST /records"},
+		},
+		{
+			name: "update",
+			records: []hetznerRecord{
+				{ID: "rec1", ZoneID: "zone1", Type: "A", Name: "home", Value: "198.51.100.1", TTL: 86400},
+				{ID: "rec2", ZoneID: "zone1", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+			},
+			calls: []string{"PUT /records/rec1"},
+		},
+		{
+			name: "several records",
+			records: []hetznerRecord{
+				{ID: "rec3", ZoneID: "zone1", Type: "A", Name: "home", Value: "198.51.100.3"},
+				{ID: "rec2", ZoneID: "zone1", Type: "A", Name: "home", Value: "203.0.113.1"},
+				{ID: "rec1", ZoneID: "zone1", Type: "A", Name: "home", Value: "198.51.100.1"},
+			},
+			calls: []string{"PUT /records/rec2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, f := fakeHetzner(t, tt.records)
+
+			if err := p.UpdateRecord(context.Background(), "example.com", "home.example.com", RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertWrites(t, f, tt.calls, []map[string]interface{}{
+				{"zone_id": "zone1", "type": "A", "name": "home", "value": "203.0.113.1", "ttl": float64(60)},
+			})
+		})
+	}
+}
+
+func TestHetznerProvider_UpdateRecordErrors(t *testing.T) {
+	p, f := fakeHetzner(t, []hetznerRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "198.51.100.1"},
+	})
+
+	err := p.UpdateRecord(context.Background(), "example.org", "home", RecordTypeA, "203.0.113.1", time.Minute)
+	if !IsPermanent(err) || !strings.Contains(err.Error(), "zone example.org not found") {
+		t.Errorf("expected permanent error for unknown zone, got %v", err)
+	}
+	assertWrites(t, f, nil, nil)
+
+	// The zone ID is cached.
+	p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	lookups := 0
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, "GET /zones?name=example.com") {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected zone lookup to be cached, got %d lookups", lookups)
+	}
+}
+
+func TestHetznerProvider_GetRecords(t *testing.T) {
+	p, _ := fakeHetzner(t, []hetznerRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "198.51.100.1"},
+		{ID: "rec2", Type: "A", Name: "other", Value: "198.51.100.2"},
+	})
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home.example.com", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,184 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linodeBaseURL is the Linode API v4 endpoint.
+const linodeBaseURL = "https://api.linode.com/v4"
+
+// LinodeProvider updates records through the Linode Domains API.
+type LinodeProvider struct {
+	client *restClient
+
+	mu        sync.Mutex
+	domainIDs map[string]int
+}
+
+// NewLinodeProvider creates a new Linode DNS provider.
+func NewLinodeProvider(config Config) (*LinodeProvider, error) {
+	if config.LinodeAPIToken == "" {
+		return nil, fmt.Errorf("Linode provider requires LINODE_API_TOKEN")
+	}
+
+	header := http.Header{"Authorization": {"Bearer " + config.LinodeAPIToken}}
+	return &LinodeProvider{
+		client:    newRESTClient("Linode", linodeBaseURL, header, linodeErrorMessage),
+		domainIDs: make(map[string]int),
+	}, nil
+}
+
+// linodeErrorMessage extracts the reasons of an error response.
+func linodeErrorMessage(body []byte) string {
+	var resp struct {
+		Errors []struct {
+			Field  string `json:"field"`
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	var reasons []string
+	for _, e := range resp.Errors {
+		if e.Field != "" {
+			reasons = append(reasons, e.Field+": "+e.Reason)
+		} else {
+			reasons = append(reasons, e.Reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// linodeRecord is a DNS record as represented by the Linode API.
+type linodeRecord struct {
+	ID     int    `json:"id,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Name   string `json:"name"` // relative to the domain, empty for the apex
+	Target string `json:"target"`
+	TTL    int    `json:"ttl_sec"`
+}
+
+// linodePage is the envelope of a paginated Linode API response.
+type linodePage[T any] struct {
+	Data  []T `json:"data"`
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+}
+
+// UpdateRecord updates an A or AAAA record using the Linode provider. An
+// existing record is updated in place; a missing one is created.
+func (l *LinodeProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if err := validateIP(ip, recordType); err != nil {
+		return Permanent(err)
+	}
+
+	domainID, err := l.domainID(ctx, zone)
+	if err != nil {
+		return err
+	}
+	relative := relativeRecordName(name, zone, "")
+
+	existing, err := l.findRecords(ctx, domainID, relative, recordType)
+	if err != nil {
+		return err
+	}
+	match := pickMatch(existing, ip,
+		func(r linodeRecord) string { return r.Target },
+		func(a, b linodeRecord) bool { return a.ID < b.ID })
+
+	// Linode rounds the TTL up to the nearest value it supports.
+	record := linodeRecord{Name: relative, Target: ip, TTL: int(ttl.Seconds())}
+	if match != nil {
+		return l.client.do(ctx, http.MethodPut, fmt.Sprintf("/domains/%d/records/%d", domainID, match.ID), record, nil)
+	}
+	record.Type = recordType
+	return l.client.do(ctx, http.MethodPost, fmt.Sprintf("/domains/%d/records", domainID), record, nil)
+}
+
+// GetRecords returns the contents of the records with the given name and
+// type.
+func (l *LinodeProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	domainID, err := l.domainID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := l.findRecords(ctx, domainID, relativeRecordName(name, zone, ""), recordType)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, rec := range existing {
+		addrs = append(addrs, rec.Target)
+	}
+	return addrs, nil
+}
+
+// findRecords returns the records of the domain with the given relative
+// name and type.
+func (l *LinodeProvider) findRecords(ctx context.Context, domainID int, name, recordType string) ([]linodeRecord, error) {
+	var records []linodeRecord
+	for page := 1; ; page++ {
+		query := url.Values{"page": {fmt.Sprint(page)}, "page_size": {"500"}}
+		var resp linodePage[linodeRecord]
+		if err := l.client.do(ctx, http.MethodGet, fmt.Sprintf("/domains/%d/records?%s", domainID, query.Encode()), nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, rec := range resp.Data {
+			if rec.Type == recordType && strings.EqualFold(rec.Name, name) {
+				records = append(records, rec)
+			}
+		}
+		if page >= resp.Pages {
+			return records, nil
+		}
+	}
+}
+
+// domainID returns the ID of the domain named zone.
+func (l *LinodeProvider) domainID(ctx context.Context, zone string) (int, error) {
+	zone = strings.TrimSuffix(zone, ".")
+
+	l.mu.Lock()
+	id, ok := l.domainIDs[zone]
+	l.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	for page := 1; id == 0; page++ {
+		query := url.Values{"page": {fmt.Sprint(page)}, "page_size": {"500"}}
+		var resp linodePage[struct {
+			ID     int    `json:"id"`
+			Domain string `json:"domain"`
+		}]
+		if err := l.client.do(ctx, http.MethodGet, "/domains?"+query.Encode(), nil, &resp); err != nil {
+			return 0, err
+		}
+		for _, d := range resp.Data {
+			if strings.EqualFold(d.Domain, zone) {
+				id = d.ID
+			}
+		}
+		if page >= resp.Pages {
+			break
+		}
+	}
+	if id == 0 {
+		return 0, Permanent(fmt.Errorf("domain %s not found", zone))
+	}
+
+	l.mu.Lock()
+	l.domainIDs[zone] = id
+	l.mu.Unlock()
+	return id, nil
+}
@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeLinode serves domain example.com with ID 42, containing records.
+func fakeLinode(t *testing.T, records []linodeRecord) (*LinodeProvider, *fakeAPI) {
+	t.Helper()
+	p, err := NewLinodeProvider(Config{LinodeAPIToken: "token"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := &fakeAPI{handle: func(r *http.Request) (int, interface{}) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			return http.StatusUnauthorized, map[string]interface{}{"errors": []map[string]string{{"reason": "Invalid Token"}}}
+		}
+		switch {
+		case r.URL.Path == "/domains":
+			domains := []map[string]interface{}{{"id": 41, "domain": "example.net"}, {"id": 42, "domain": "example.com"}}
+			return http.StatusOK, map[string]interface{}{"data": domains, "page": 1, "pages": 1}
+		case r.URL.Path == "/domains/42/records" && r.Method == http.MethodGet:
+			return http.StatusOK, map[string]interface{}{"data": records, "page": 1, "pages": 1}
+		default:
+			return http.StatusOK, map[string]int{"id": 1}
+		}
+	}}
+	f.start(t, p.client)
+	return p, f
+}
+
+func TestLinodeProvider_UpdateRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []linodeRecord
+		recName string
+		calls   []string
+		bodies  []map[string]interface{}
+	}{
+		{
+			name:    "create",
+			recName: "home.example.com",
+			calls:   []string{"POST /domains/42/records"},
+			bodies: []map[string]interface{}{
+				{"type": "A", "name": "home", "target": "203.0.113.1", "ttl_sec": float64(60)},
+			},
+		},
+		{
+			name:    "update",
+			records: []linodeRecord{{ID: 7, Type: "A", Name: "home", Target: "198.51.100.1"}},
+			recName: "home",
+			calls:   []string{"PUT /domains/42/records/7"},
+			bodies: []map[string]interface{}{
+				{"name": "home", "target": "203.0.113.1", "ttl_sec": float64(60)},
+			},
+		},
+		{
+			name: "several records",
+			records: []linodeRecord{
+				{ID: 8, Type: "A", Name: "home", Target: "198.51.100.2"},
+				{ID: 7, Type: "A", Name: "home", Target: "198.51.100.1"},
+			},
+			recName: "home",
+			calls:   []string{"PUT /domains/42/records/7"},
+			bodies: []map[string]interface{}{
+				{"name": "home", "target": "203.0.113.1", "ttl_sec": float64(60)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, f := fakeLinode(t, tt.records)
+
+			if err := p.UpdateRecord(context.Background(), "example.com", tt.recName, RecordTypeA, "203.0.113.1", time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertWrites(t, f, tt.calls, tt.bodies)
+		})
+	}
+}
+
+func TestLinodeProvider_UpdateRecordErrors(t *testing.T) {
+	p, f := fakeLinode(t, nil)
+
+	if err := p.UpdateRecord(context.Background(), "example.org", "home", RecordTypeA, "203.0.113.1", time.Minute); !IsPermanent(err) {
+		t.Errorf("expected permanent error for unknown domain, got %v", err)
+	}
+	assertWrites(t, f, nil, nil)
+
+	p.client.header.Set("Authorization", "Bearer wrong")
+	err := p.UpdateRecord(context.Background(), "example.net", "home", RecordTypeA, "203.0.113.1", time.Minute)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid Token" {
+		t.Errorf("expected HTTP 401 with reason, got %v", err)
+	}
+}
+
+func TestLinodeErrorMessage(t *testing.T) {
+	body := `{"errors": [{"field": "target", "reason": "Invalid IPv4 address"}, {"reason": "Too many records"}]}`
+	if got := linodeErrorMessage([]byte(body)); got != "target: Invalid IPv4 address; Too many records" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestLinodeProvider_GetRecords(t *testing.T) {
+	p, _ := fakeLinode(t, []linodeRecord{
+		{ID: 7, Type: "AAAA", Name: "home", Target: "2001:db8::1"},
+		{ID: 8, Type: "AAAA", Name: "", Target: "2001:db8::2"},
+	})
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home.example.com", RecordTypeAAAA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "2001:db8::1" {
+		t.Errorf("expected [2001:db8::1], got %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// accessToken caches an OAuth 2.0 access token until shortly before it
+// expires.
+type accessToken struct {
+	// fetch obtains a new token and its lifetime.
+	fetch func(ctx context.Context) (string, time.Duration, error)
+	now   func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newAccessToken(fetch func(ctx context.Context) (string, time.Duration, error)) *accessToken {
+	return &accessToken{fetch: fetch, now: time.Now}
+}
+
+// get returns the cached token, fetching a new one if it is about to
+// expire.
+func (t *accessToken) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && t.now().Before(t.expiry) {
+		return t.token, nil
+	}
+	token, lifetime, err := t.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	t.token = token
+	t.expiry = t.now().Add(lifetime - time.Minute)
+	return token, nil
+}
+
+// authorize sets the Authorization header of req to the bearer token.
+func (t *accessToken) authorize(ctx context.Context, req *http.Request) error {
+	token, err := t.get(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// requestToken sends req to a token endpoint and returns the access token
+// and its lifetime.
+func requestToken(httpClient *http.Client, req *http.Request, provider string) (string, time.Duration, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to obtain %s access token: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to obtain %s access token: %w", provider, err)
+	}
+
+	// Some metadata services send expires_in as a string.
+	var token struct {
+		AccessToken      string      `json:"access_token"`
+		ExpiresIn        json.Number `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	decodeErr := json.Unmarshal(data, &token)
+	if resp.StatusCode != http.StatusOK {
+		message := token.ErrorDescription
+		if message == "" {
+			message = token.Error
+		}
+		if message == "" {
+			message = resp.Status
+		}
+		return "", 0, &APIError{Provider: provider + " token", StatusCode: resp.StatusCode, Message: message}
+	}
+	if decodeErr != nil || token.AccessToken == "" {
+		return "", 0, fmt.Errorf("invalid %s token response", provider)
+	}
+
+	seconds, err := token.ExpiresIn.Int64()
+	if err != nil || seconds <= 0 {
+		seconds = 300
+	}
+	return token.AccessToken, time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,42 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccessToken(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetches := 0
+	token := newAccessToken(func(ctx context.Context) (string, time.Duration, error) {
+		fetches++
+		if fetches == 3 {
+			return "", 0, errors.New("token endpoint unavailable")
+		}
+		return "token", time.Hour, nil
+	})
+	token.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if got, err := token.get(context.Background()); err != nil || got != "token" {
+			t.Fatalf("expected token, got %q, %v", got, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected token to be cached, got %d fetches", fetches)
+	}
+
+	// Tokens are renewed shortly before they expire.
+	now = now.Add(59*time.Minute + time.Second)
+	token.get(context.Background())
+	if fetches != 2 {
+		t.Errorf("expected token to be renewed, got %d fetches", fetches)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := token.get(context.Background()); err == nil {
+		t.Error("expected error from token endpoint")
+	}
+}
@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PowerDNSProvider updates records through the PowerDNS Authoritative
+// Server HTTP API.
+type PowerDNSProvider struct {
+	client *restClient // API endpoint of the server, e.g. ".../api/v1/servers/localhost"
+}
+
+// NewPowerDNSProvider creates a new PowerDNS DNS provider.
+func NewPowerDNSProvider(config Config) (*PowerDNSProvider, error) {
+	if config.PDNSAPIURL == "" {
+		return nil, fmt.Errorf("PowerDNS provider requires PDNS_API_URL")
+	}
+	if config.PDNSAPIKey == "" {
+		return nil, fmt.Errorf("PowerDNS provider requires PDNS_API_KEY")
+	}
+	serverID := config.PDNSServerID
+	if serverID == "" {
+		serverID = "localhost"
+	}
+
+	apiURL := strings.TrimSuffix(strings.TrimSuffix(config.PDNSAPIURL, "/"), "/api/v1")
+	header := http.Header{"X-API-Key": {config.PDNSAPIKey}}
+	return &PowerDNSProvider{
+		client: newRESTClient("PowerDNS", apiURL+"/api/v1/servers/"+url.PathEscape(serverID), header, powerDNSErrorMessage),
+	}, nil
+}
+
+// powerDNSErrorMessage extracts the message of an error response.
+func powerDNSErrorMessage(body []byte) string {
+	var resp struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(body, &resp)
+	return resp.Error
+}
+
+// powerDNSRRset is a resource record set as represented by the PowerDNS API.
+type powerDNSRRset struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	TTL        int              `json:"ttl,omitempty"`
+	ChangeType string           `json:"changetype,omitempty"`
+	Records    []powerDNSRecord `json:"records"`
+}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+// powerDNSPatch is the body of a zone PATCH request. PowerDNS applies all
+// record sets of a request in a single transaction.
+type powerDNSPatch struct {
+	RRsets []powerDNSRRset `json:"rrsets"`
+}
+
+// UpdateRecord updates an A or AAAA record using the PowerDNS provider.
+func (p *PowerDNSProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	return p.UpdateRecords(ctx, zone, []Record{{Name: name, Type: recordType, IP: ip, TTL: ttl}})[0]
+}
+
+// UpdateRecords replaces the record sets with a single PATCH request.
+func (p *PowerDNSProvider) UpdateRecords(ctx context.Context, zone string, records []Record) []error {
+	errs := make([]error, len(records))
+	var rrsets []powerDNSRRset
+	var indexes []int
+	for i, rec := range records {
+		if err := validateIP(rec.IP, rec.Type); err != nil {
+			errs[i] = Permanent(err)
+			continue
+		}
+		rrsets = append(rrsets, powerDNSRRset{
+			Name:       absoluteName(rec.Name, zone),
+			Type:       rec.Type,
+			TTL:        int(rec.TTL.Seconds()),
+			ChangeType: "REPLACE",
+			Records:    []powerDNSRecord{{Content: rec.IP}},
+		})
+		indexes = append(indexes, i)
+	}
+	if len(rrsets) == 0 {
+		return errs
+	}
+
+	return applyBatch(rrsets, indexes, errs, func(rrsets []powerDNSRRset) error {
+		return p.patch(ctx, zone, rrsets)
+	}, isRejectedPatch)
+}
+
+// GetRecords returns the enabled addresses of the record set with the given
+// name and type.
+func (p *PowerDNSProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+	fqdn := absoluteName(name, zone)
+
+	// Servers that do not support filtering return the whole zone.
+	query := url.Values{"rrset_name": {fqdn}, "rrset_type": {recordType}}
+	var found struct {
+		RRsets []powerDNSRRset `json:"rrsets"`
+	}
+	if err := p.client.do(ctx, http.MethodGet, p.zonePath(zone)+"?"+query.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, rrset := range found.RRsets {
+		if !strings.EqualFold(rrset.Name, fqdn) || rrset.Type != recordType {
+			continue
+		}
+		for _, rec := range rrset.Records {
+			if !rec.Disabled {
+				addrs = append(addrs, rec.Content)
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// isRejectedPatch reports whether PowerDNS rejected a PATCH request because
+// of its contents.
+func isRejectedPatch(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// zonePath returns the API path of the zone, which PowerDNS identifies by
+// its canonical name.
+func (p *PowerDNSProvider) zonePath(zone string) string {
+	return "/zones/" + url.PathEscape(normalizeZone(zone))
+}
+
+// patch submits changes to the record sets of the zone.
+func (p *PowerDNSProvider) patch(ctx context.Context, zone string, rrsets []powerDNSRRset) error {
+	return p.client.do(ctx, http.MethodPatch, p.zonePath(zone), powerDNSPatch{RRsets: rrsets}, nil)
+}
@@ -0,0 +1,249 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePowerDNS is an in-memory stand-in for the PowerDNS API of server
+// "localhost".
+type fakePowerDNS struct {
+	mu       sync.Mutex
+	apiKey   string
+	zones    map[string][]powerDNSRRset // canonical zone name -> record sets
+	patches  []powerDNSPatch
+	rejected map[string]bool // record set names the server refuses
+}
+
+func newFakePowerDNS(t *testing.T, f *fakePowerDNS) *PowerDNSProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	p, err := NewPowerDNSProvider(Config{PDNSAPIURL: srv.URL + "/", PDNSAPIKey: f.apiKey})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.client.httpClient = srv.Client()
+	return p
+}
+
+func (f *fakePowerDNS) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func (f *fakePowerDNS) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("X-API-Key") != f.apiKey {
+		f.reply(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	zoneName, ok := strings.CutPrefix(r.URL.Path, "/api/v1/servers/localhost/zones/")
+	rrsets, exists := f.zones[zoneName]
+	if !ok || !exists {
+		f.reply(w, http.StatusNotFound, map[string]string{"error": "Could not find domain '" + zoneName + "'"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		f.reply(w, http.StatusOK, map[string]interface{}{"name": zoneName, "rrsets": rrsets})
+	case http.MethodPatch:
+		var patch powerDNSPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			f.reply(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		f.patches = append(f.patches, patch)
+		for _, rrset := range patch.RRsets {
+			if f.rejected[rrset.Name] || rrset.ChangeType != "REPLACE" {
+				f.reply(w, http.StatusUnprocessableEntity, map[string]string{"error": "RRset " + rrset.Name + " IN " + rrset.Type + ": invalid"})
+				return
+			}
+		}
+		for _, rrset := range patch.RRsets {
+			replaced := false
+			for i, existing := range rrsets {
+				if existing.Name == rrset.Name && existing.Type == rrset.Type {
+					rrsets[i] = rrset
+					replaced = true
+				}
+			}
+			if !replaced {
+				rrsets = append(rrsets, rrset)
+			}
+		}
+		f.zones[zoneName] = rrsets
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.reply(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+	}
+}
+
+func TestPowerDNSProvider_UpdateRecords(t *testing.T) {
+	f := &fakePowerDNS{
+		apiKey: "secret",
+		zones: map[string][]powerDNSRRset{"example.com.": {
+			{Name: "foo.example.com.", Type: "A", TTL: 300, Records: []powerDNSRecord{{Content: "198.51.100.1"}}},
+		}},
+	}
+	p := newFakePowerDNS(t, f)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "foo.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bar", Type: RecordTypeAAAA, IP: "2001:db8::1", TTL: time.Minute},
+		{Name: "baz.example.com", Type: RecordTypeA, IP: "not-an-ip", TTL: time.Minute},
+	})
+
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !IsPermanent(errs[2]) {
+		t.Errorf("expected permanent error for invalid address, got %v", errs[2])
+	}
+	if len(f.patches) != 1 || len(f.patches[0].RRsets) != 2 {
+		t.Fatalf("expected 1 PATCH with 2 record sets, got %+v", f.patches)
+	}
+	bar := f.patches[0].RRsets[1]
+	if bar.Name != "bar.example.com." || bar.Type != "AAAA" || bar.TTL != 60 || bar.Records[0].Content != "2001:db8::1" {
+		t.Errorf("unexpected record set %+v", bar)
+	}
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "foo", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "203.0.113.1" {
+		t.Errorf("expected A record to be replaced, got %v, %v", addrs, err)
+	}
+}
+
+func TestPowerDNSProvider_UpdateRecordsAttributesErrors(t *testing.T) {
+	f := &fakePowerDNS{
+		apiKey:   "secret",
+		zones:    map[string][]powerDNSRRset{"example.com.": {}},
+		rejected: map[string]bool{"bad.example.com.": true},
+	}
+	p := newFakePowerDNS(t, f)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "good.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bad.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+
+	if errs[0] != nil {
+		t.Errorf("expected good record to be updated, got %v", errs[0])
+	}
+	var pdnsErr *APIError
+	if !errors.As(errs[1], &pdnsErr) || pdnsErr.HTTPStatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("expected HTTP 422 for bad record, got %v", errs[1])
+	}
+	// One failed request followed by one request per record.
+	if len(f.patches) != 3 {
+		t.Errorf("expected 3 PATCH requests, got %d", len(f.patches))
+	}
+}
+
+func TestPowerDNSProvider_UpdateRecordErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiKey string
+		zone   string
+		status int
+	}{
+		{name: "bad key", apiKey: "wrong", zone: "example.com", status: http.StatusUnauthorized},
+		{name: "zone not found", apiKey: "secret", zone: "example.org", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakePowerDNS{apiKey: "secret", zones: map[string][]powerDNSRRset{"example.com.": {}}}
+			p := newFakePowerDNS(t, f)
+			p.client.header["X-API-Key"] = []string{tt.apiKey}
+
+			err := p.UpdateRecord(context.Background(), tt.zone, "foo", RecordTypeA, "203.0.113.1", time.Minute)
+			var pdnsErr *APIError
+			if !errors.As(err, &pdnsErr) || pdnsErr.HTTPStatusCode() != tt.status {
+				t.Errorf("expected HTTP %d, got %v", tt.status, err)
+			}
+		})
+	}
+}
+
+func TestPowerDNSProvider_GetRecords(t *testing.T) {
+	f := &fakePowerDNS{
+		apiKey: "secret",
+		zones: map[string][]powerDNSRRset{"example.com.": {
+			{Name: "foo.example.com.", Type: "A", Records: []powerDNSRecord{
+				{Content: "198.51.100.1"},
+				{Content: "198.51.100.2", Disabled: true},
+			}},
+			{Name: "foo.example.com.", Type: "AAAA", Records: []powerDNSRecord{{Content: "2001:db8::1"}}},
+		}},
+	}
+	p := newFakePowerDNS(t, f)
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "foo.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v", addrs)
+	}
+
+	addrs, err = p.GetRecords(context.Background(), "example.com", "missing.example.com", RecordTypeA)
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("expected no addresses for missing record, got %v, %v", addrs, err)
+	}
+}
+
+func TestNewPowerDNSProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "default server",
+			config:   Config{PDNSAPIURL: "http://127.0.0.1:8081", PDNSAPIKey: "secret"},
+			expected: "http://127.0.0.1:8081/api/v1/servers/localhost",
+		},
+		{
+			name:     "API path and server ID",
+			config:   Config{PDNSAPIURL: "https://pdns.example.com/api/v1/", PDNSServerID: "primary", PDNSAPIKey: "secret"},
+			expected: "https://pdns.example.com/api/v1/servers/primary",
+		},
+		{name: "missing URL", config: Config{PDNSAPIKey: "secret"}, expectErr: true},
+		{name: "missing key", config: Config{PDNSAPIURL: "http://127.0.0.1:8081"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPowerDNSProvider(tt.config)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.client.baseURL != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, p.client.baseURL)
+			}
+		})
+	}
+}
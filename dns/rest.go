@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is returned when the HTTP API of a provider rejects a request.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (HTTP %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// HTTPStatusCode returns the HTTP status code of the response.
+func (e *APIError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// restClient sends JSON requests to the HTTP API of a provider.
+type restClient struct {
+	httpClient *http.Client
+	provider   string
+	baseURL    string
+	header     http.Header // sent with every request, e.g. credentials
+	// authorize, if set, adds credentials that change over time, such as
+	// OAuth access tokens, to every request.
+	authorize func(ctx context.Context, req *http.Request) error
+	// errorMessage extracts the message from the body of an error
+	// response. It returns "" if the body has none.
+	errorMessage func(body []byte) string
+}
+
+func newRESTClient(provider, baseURL string, header http.Header, errorMessage func(body []byte) string) *restClient {
+	return &restClient{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		provider:     provider,
+		baseURL:      baseURL,
+		header:       header,
+		errorMessage: errorMessage,
+	}
+}
+
+// do sends a request with body encoded as JSON and decodes the response
+// into out. Responses other than 2xx are returned as an *APIError.
+func (c *restClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if c.authorize != nil {
+		if err := c.authorize(ctx, req); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		message := resp.Status
+		if c.errorMessage != nil {
+			if m := c.errorMessage(data); m != "" {
+				message = m
+			}
+		}
+		return &APIError{Provider: c.provider, StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("invalid %s API response: %w", c.provider, err)
+		}
+	}
+	return nil
+}
+
+// pickMatch returns the record among matches, the records of a name and
+// type, that an update to ip modifies, or nil if there is none. A record that
+// already holds ip is preferred, so the other records of a name with several
+// addresses are left alone. Otherwise the record that sorts first by less is
+// chosen, so that repeated updates modify the same record.
+func pickMatch[T any](matches []T, ip string, content func(T) string, less func(a, b T) bool) *T {
+	var pick *T
+	for i := range matches {
+		if net.ParseIP(content(matches[i])).Equal(net.ParseIP(ip)) {
+			return &matches[i]
+		}
+		if pick == nil || less(matches[i], *pick) {
+			pick = &matches[i]
+		}
+	}
+	return pick
+}
+
+// relativeRecordName returns the name of a record relative to zone, using
+// apex for the zone itself, as the REST APIs of most providers expect it.
+func relativeRecordName(name, zone, apex string) string {
+	if rel := relativeName(name, zone); rel != "@" {
+		return strings.TrimSuffix(rel, ".")
+	}
+	return apex
+}
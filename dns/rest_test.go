@@ -0,0 +1,72 @@
+package dns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeAPI is a stand-in for the HTTP API of a provider. It records every
+// request and answers it with handle.
+type fakeAPI struct {
+	mu     sync.Mutex
+	calls  []string                 // "METHOD /path?query"
+	bodies []map[string]interface{} // decoded request bodies, nil for none
+	handle func(r *http.Request) (int, interface{})
+}
+
+// start runs a server for f and points client at it.
+func (f *fakeAPI) start(t *testing.T, client *restClient) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.calls = append(f.calls, r.Method+" "+r.URL.RequestURI())
+		f.bodies = append(f.bodies, body)
+
+		status, resp := f.handle(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if resp != nil {
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client.httpClient = srv.Client()
+	client.baseURL = srv.URL
+}
+
+// writes returns the requests that modified records, with their bodies.
+func (f *fakeAPI) writes() ([]string, []map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var calls []string
+	var bodies []map[string]interface{}
+	for i, call := range f.calls {
+		if call[:4] != "GET " {
+			calls = append(calls, call)
+			bodies = append(bodies, f.bodies[i])
+		}
+	}
+	return calls, bodies
+}
+
+// assertWrites checks the modifying requests made against f.
+func assertWrites(t *testing.T, f *fakeAPI, calls []string, bodies []map[string]interface{}) {
+	t.Helper()
+	gotCalls, gotBodies := f.writes()
+	if !reflect.DeepEqual(gotCalls, calls) {
+		t.Errorf("expected requests %v, got %v", calls, gotCalls)
+	}
+	if !reflect.DeepEqual(gotBodies, bodies) {
+		t.Errorf("expected bodies %v, got %v", bodies, gotBodies)
+	}
+}
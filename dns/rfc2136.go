@@ -0,0 +1,247 @@
+package dns
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+
+	"github.com/epsilonrhorho/dns-updater/internal/hostport"
+)
+
+// Prerequisites an RFC 2136 update can be made conditional on.
+const (
+	PrerequisiteNone        = "none"         // always apply the update
+	PrerequisiteRRsetExists = "rrset_exists" // the record must already exist
+	PrerequisiteNameExists  = "name_exists"  // the name must own some record
+)
+
+// rfc2136Algorithms maps the supported TSIG algorithm names to their
+// canonical form.
+var rfc2136Algorithms = map[string]string{
+	"hmac-sha256": mdns.HmacSHA256,
+	"hmac-sha512": mdns.HmacSHA512,
+}
+
+// RFC2136Provider updates records with DNS UPDATE messages (RFC 2136) sent
+// to the primary name server of the zone, optionally signed with TSIG.
+type RFC2136Provider struct {
+	client       *mdns.Client
+	server       string
+	keyName      string
+	algorithm    string
+	prerequisite string
+}
+
+// NewRFC2136Provider creates a new RFC 2136 DNS provider.
+func NewRFC2136Provider(config Config) (*RFC2136Provider, error) {
+	if config.RFC2136Server == "" {
+		return nil, fmt.Errorf("RFC 2136 provider requires RFC2136_SERVER")
+	}
+
+	p := &RFC2136Provider{
+		client:       &mdns.Client{Net: "tcp", Timeout: 10 * time.Second},
+		server:       hostport.WithDefaultPort(config.RFC2136Server, "53"),
+		prerequisite: PrerequisiteNone,
+	}
+
+	if config.RFC2136KeyName != "" {
+		if config.RFC2136Secret == "" {
+			return nil, fmt.Errorf("RFC 2136 provider requires RFC2136_SECRET with RFC2136_KEY_NAME")
+		}
+		if _, err := base64.StdEncoding.DecodeString(config.RFC2136Secret); err != nil {
+			return nil, fmt.Errorf("RFC2136_SECRET must be base64 encoded: %w", err)
+		}
+		algorithm := strings.ToLower(config.RFC2136Algorithm)
+		if algorithm == "" {
+			algorithm = "hmac-sha256"
+		}
+		canonical, ok := rfc2136Algorithms[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TSIG algorithm: %s (expected hmac-sha256 or hmac-sha512)", config.RFC2136Algorithm)
+		}
+		p.keyName = mdns.Fqdn(strings.ToLower(config.RFC2136KeyName))
+		p.algorithm = canonical
+		p.client.TsigSecret = map[string]string{p.keyName: config.RFC2136Secret}
+	}
+
+	switch config.RFC2136Prerequisite {
+	case "", PrerequisiteNone:
+	case PrerequisiteRRsetExists, PrerequisiteNameExists:
+		p.prerequisite = config.RFC2136Prerequisite
+	default:
+		return nil, fmt.Errorf("unsupported RFC 2136 prerequisite: %s", config.RFC2136Prerequisite)
+	}
+
+	return p, nil
+}
+
+// RcodeError is returned when the name server answers with an error code.
+type RcodeError struct {
+	Rcode int
+}
+
+func (e *RcodeError) Error() string {
+	return fmt.Sprintf("name server returned %s", mdns.RcodeToString[e.Rcode])
+}
+
+// UpdateRecord updates an A or AAAA record using the RFC 2136 provider.
+func (p *RFC2136Provider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	return p.UpdateRecords(ctx, zone, []Record{{Name: name, Type: recordType, IP: ip, TTL: ttl}})[0]
+}
+
+// UpdateRecords replaces the records in a single UPDATE message, which the
+// server applies atomically.
+func (p *RFC2136Provider) UpdateRecords(ctx context.Context, zone string, records []Record) []error {
+	errs := make([]error, len(records))
+	var rrs []mdns.RR
+	var indexes []int
+	for i, rec := range records {
+		rr, err := rfc2136RR(zone, rec)
+		if err != nil {
+			errs[i] = Permanent(err)
+			continue
+		}
+		rrs = append(rrs, rr)
+		indexes = append(indexes, i)
+	}
+	if len(rrs) == 0 {
+		return errs
+	}
+
+	// A failed prerequisite of one record fails the whole message.
+	return applyBatch(rrs, indexes, errs, func(rrs []mdns.RR) error {
+		return p.update(ctx, zone, rrs)
+	}, isRejectedUpdate)
+}
+
+// rfc2136RR builds the resource record for rec.
+func rfc2136RR(zone string, rec Record) (mdns.RR, error) {
+	if err := validateIP(rec.IP, rec.Type); err != nil {
+		return nil, err
+	}
+	hdr := mdns.RR_Header{
+		Name:  absoluteName(rec.Name, zone),
+		Class: mdns.ClassINET,
+		Ttl:   uint32(rec.TTL.Seconds()),
+	}
+	if rec.Type == RecordTypeA {
+		hdr.Rrtype = mdns.TypeA
+		return &mdns.A{Hdr: hdr, A: net.ParseIP(rec.IP)}, nil
+	}
+	hdr.Rrtype = mdns.TypeAAAA
+	return &mdns.AAAA{Hdr: hdr, AAAA: net.ParseIP(rec.IP)}, nil
+}
+
+// update sends one UPDATE message replacing the record sets of rrs.
+func (p *RFC2136Provider) update(ctx context.Context, zone string, rrs []mdns.RR) error {
+	msg := new(mdns.Msg)
+	msg.SetUpdate(normalizeZone(zone))
+
+	var prereqs []mdns.RR
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		switch p.prerequisite {
+		case PrerequisiteRRsetExists:
+			prereqs = append(prereqs, &mdns.ANY{Hdr: mdns.RR_Header{Name: hdr.Name, Rrtype: hdr.Rrtype}})
+		case PrerequisiteNameExists:
+			prereqs = append(prereqs, &mdns.ANY{Hdr: mdns.RR_Header{Name: hdr.Name, Rrtype: mdns.TypeANY}})
+		}
+	}
+	if len(prereqs) > 0 {
+		msg.RRsetUsed(prereqs)
+	}
+
+	removals := make([]mdns.RR, len(rrs))
+	for i, rr := range rrs {
+		removals[i] = &mdns.ANY{Hdr: mdns.RR_Header{Name: rr.Header().Name, Rrtype: rr.Header().Rrtype}}
+	}
+	msg.RemoveRRset(removals)
+	msg.Insert(rrs)
+
+	_, err := p.exchange(ctx, msg)
+	return err
+}
+
+// GetRecords returns the addresses of the record with the given name and
+// type, as served by the configured server.
+func (p *RFC2136Provider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+
+	msg := new(mdns.Msg)
+	msg.SetQuestion(absoluteName(name, zone), mdns.StringToType[recordType])
+	msg.RecursionDesired = false
+
+	resp, err := p.exchange(ctx, msg)
+	var rcodeErr *RcodeError
+	if errors.As(err, &rcodeErr) && rcodeErr.Rcode == mdns.RcodeNameError {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, rr := range resp.Answer {
+		switch r := rr.(type) {
+		case *mdns.A:
+			if recordType == RecordTypeA {
+				addrs = append(addrs, r.A.String())
+			}
+		case *mdns.AAAA:
+			if recordType == RecordTypeAAAA {
+				addrs = append(addrs, r.AAAA.String())
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// exchange signs msg if a key is configured, sends it to the server and
+// classifies the error codes of the response.
+func (p *RFC2136Provider) exchange(ctx context.Context, msg *mdns.Msg) (*mdns.Msg, error) {
+	if p.keyName != "" {
+		msg.SetTsig(p.keyName, p.algorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err := p.client.ExchangeContext(ctx, msg, p.server)
+	if err != nil {
+		// A response signed with the wrong key is as fatal as a rejected one.
+		if errors.Is(err, mdns.ErrSig) || errors.Is(err, mdns.ErrSecret) {
+			return nil, Permanent(err)
+		}
+		return nil, err
+	}
+
+	switch resp.Rcode {
+	case mdns.RcodeSuccess:
+		return resp, nil
+	case mdns.RcodeServerFailure:
+		return nil, &RcodeError{Rcode: resp.Rcode}
+	default:
+		// Refused and NotAuth cover missing or rejected keys, the
+		// others a wrong zone, a failed prerequisite or a bad message.
+		return nil, Permanent(&RcodeError{Rcode: resp.Rcode})
+	}
+}
+
+// isRejectedUpdate reports whether the server refused an update because of
+// its contents rather than the credentials or the zone.
+func isRejectedUpdate(err error) bool {
+	var rcodeErr *RcodeError
+	if !errors.As(err, &rcodeErr) {
+		return false
+	}
+	switch rcodeErr.Rcode {
+	case mdns.RcodeYXDomain, mdns.RcodeYXRrset, mdns.RcodeNXRrset, mdns.RcodeNameError, mdns.RcodeFormatError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+const (
+	testKeyName = "update-key."
+	testSecret  = "c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldA=="
+)
+
+// fakeRFC2136 is an in-process primary server for example.com that applies
+// signed UPDATE messages to an in-memory zone.
+type fakeRFC2136 struct {
+	mu      sync.Mutex
+	zone    map[string][]mdns.RR // "name/type" -> record set
+	updates int
+}
+
+func rrsetKey(name string, rrtype uint16) string {
+	return strings.ToLower(name) + "/" + mdns.TypeToString[rrtype]
+}
+
+func (f *fakeRFC2136) serveDNS(w mdns.ResponseWriter, r *mdns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := new(mdns.Msg)
+	m.SetReply(r)
+	defer func() {
+		if tsig := r.IsTsig(); tsig != nil {
+			m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+		}
+		_ = w.WriteMsg(m)
+	}()
+
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		m.Rcode = mdns.RcodeNotAuth
+		return
+	}
+
+	if r.Opcode == mdns.OpcodeQuery {
+		q := r.Question[0]
+		m.Authoritative = true
+		m.Answer = f.zone[rrsetKey(q.Name, q.Qtype)]
+		return
+	}
+
+	f.updates++
+	if r.Question[0].Name != "example.com." {
+		m.Rcode = mdns.RcodeNotZone
+		return
+	}
+	for _, prereq := range r.Answer {
+		hdr := prereq.Header()
+		if hdr.Rrtype == mdns.TypeANY {
+			found := false
+			for key := range f.zone {
+				found = found || strings.HasPrefix(key, strings.ToLower(hdr.Name)+"/")
+			}
+			if !found {
+				m.Rcode = mdns.RcodeNameError
+				return
+			}
+		} else if len(f.zone[rrsetKey(hdr.Name, hdr.Rrtype)]) == 0 {
+			m.Rcode = mdns.RcodeNXRrset
+			return
+		}
+	}
+	for _, rr := range r.Ns {
+		hdr := rr.Header()
+		key := rrsetKey(hdr.Name, hdr.Rrtype)
+		if hdr.Class == mdns.ClassANY {
+			delete(f.zone, key)
+		} else {
+			f.zone[key] = append(f.zone[key], rr)
+		}
+	}
+}
+
+func newFakeRFC2136(t *testing.T, f *fakeRFC2136, config Config) *RFC2136Provider {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on tcp: %v", err)
+	}
+
+	srv := &mdns.Server{
+		Listener:   l,
+		Handler:    mdns.HandlerFunc(f.serveDNS),
+		TsigSecret: map[string]string{testKeyName: testSecret},
+		// The default accept function only lets queries and notifies through.
+		MsgAcceptFunc: func(dh mdns.Header) mdns.MsgAcceptAction { return mdns.MsgAccept },
+	}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	config.RFC2136Server = l.Addr().String()
+	p, err := NewRFC2136Provider(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+func mustRR(t *testing.T, s string) mdns.RR {
+	t.Helper()
+	rr, err := mdns.NewRR(s)
+	if err != nil {
+		t.Fatalf("invalid record %q: %v", s, err)
+	}
+	return rr
+}
+
+func TestRFC2136Provider_UpdateRecords(t *testing.T) {
+	f := &fakeRFC2136{zone: map[string][]mdns.RR{
+		"foo.example.com./A": {mustRR(t, "foo.example.com. 300 IN A 198.51.100.1")},
+	}}
+	p := newFakeRFC2136(t, f, Config{RFC2136KeyName: "update-key", RFC2136Secret: testSecret})
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "foo.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "foo", Type: RecordTypeAAAA, IP: "2001:db8::1", TTL: time.Minute},
+		{Name: "bar.example.com", Type: RecordTypeA, IP: "2001:db8::1", TTL: time.Minute},
+	})
+
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !IsPermanent(errs[2]) {
+		t.Errorf("expected permanent error for wrong address family, got %v", errs[2])
+	}
+	if f.updates != 1 {
+		t.Errorf("expected 1 UPDATE message, got %d", f.updates)
+	}
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "foo.example.com", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "203.0.113.1" {
+		t.Errorf("expected A record to be replaced, got %v, %v", addrs, err)
+	}
+	addrs, err = p.GetRecords(context.Background(), "example.com", "foo.example.com", RecordTypeAAAA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "2001:db8::1" {
+		t.Errorf("expected AAAA record to be added, got %v, %v", addrs, err)
+	}
+	if ttl := f.zone["foo.example.com./AAAA"][0].Header().Ttl; ttl != 60 {
+		t.Errorf("expected TTL 60, got %d", ttl)
+	}
+
+	addrs, err = p.GetRecords(context.Background(), "example.com", "missing.example.com", RecordTypeA)
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("expected no addresses for missing record, got %v, %v", addrs, err)
+	}
+}
+
+func TestRFC2136Provider_Prerequisite(t *testing.T) {
+	f := &fakeRFC2136{zone: map[string][]mdns.RR{
+		"foo.example.com./A": {mustRR(t, "foo.example.com. 300 IN A 198.51.100.1")},
+	}}
+	p := newFakeRFC2136(t, f, Config{
+		RFC2136KeyName:      "update-key",
+		RFC2136Secret:       testSecret,
+		RFC2136Algorithm:    "HMAC-SHA256",
+		RFC2136Prerequisite: PrerequisiteRRsetExists,
+	})
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "foo.example.com", Type: RecordTypeA, IP: "203.0.113.1"},
+		{Name: "typo.example.com", Type: RecordTypeA, IP: "203.0.113.1"},
+	})
+
+	if errs[0] != nil {
+		t.Errorf("expected existing record to be updated, got %v", errs[0])
+	}
+	var rcodeErr *RcodeError
+	if !IsPermanent(errs[1]) || !errors.As(errs[1], &rcodeErr) || rcodeErr.Rcode != mdns.RcodeNXRrset {
+		t.Errorf("expected NXRRSET for missing record, got %v", errs[1])
+	}
+	// One failed message followed by one message per record.
+	if f.updates != 3 {
+		t.Errorf("expected 3 UPDATE messages, got %d", f.updates)
+	}
+	if _, ok := f.zone["typo.example.com./A"]; ok {
+		t.Error("record should not have been created")
+	}
+}
+
+func TestRFC2136Provider_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		zone   string
+	}{
+		{name: "unsigned", zone: "example.com"},
+		{name: "wrong zone", zone: "example.org", config: Config{RFC2136KeyName: "update-key", RFC2136Secret: testSecret}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRFC2136{zone: map[string][]mdns.RR{}}
+			p := newFakeRFC2136(t, f, tt.config)
+
+			err := p.UpdateRecord(context.Background(), tt.zone, "foo", RecordTypeA, "203.0.113.1", time.Minute)
+			if !IsPermanent(err) {
+				t.Errorf("expected permanent error, got %v", err)
+			}
+			if len(f.zone) != 0 {
+				t.Errorf("expected zone to be unchanged, got %v", f.zone)
+			}
+		})
+	}
+}
+
+func TestNewRFC2136Provider(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr bool
+	}{
+		{name: "server only", config: Config{RFC2136Server: "ns1.example.com"}},
+		{name: "hmac-sha512", config: Config{RFC2136Server: "ns1.example.com", RFC2136KeyName: "key", RFC2136Secret: testSecret, RFC2136Algorithm: "hmac-sha512"}},
+		{name: "missing server", config: Config{}, expectErr: true},
+		{name: "missing secret", config: Config{RFC2136Server: "ns1.example.com", RFC2136KeyName: "key"}, expectErr: true},
+		{name: "invalid secret", config: Config{RFC2136Server: "ns1.example.com", RFC2136KeyName: "key", RFC2136Secret: "not base64!"}, expectErr: true},
+		{name: "unsupported algorithm", config: Config{RFC2136Server: "ns1.example.com", RFC2136KeyName: "key", RFC2136Secret: testSecret, RFC2136Algorithm: "hmac-md5"}, expectErr: true},
+		{name: "unsupported prerequisite", config: Config{RFC2136Server: "ns1.example.com", RFC2136Prerequisite: "maybe"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewRFC2136Provider(tt.config)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.server != "ns1.example.com:53" {
+				t.Errorf("expected default port, got %s", p.server)
+			}
+		})
+	}
+}
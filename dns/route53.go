@@ -2,40 +2,374 @@ package dns
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/libdns/libdns"
-	"github.com/libdns/route53"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-// Route53Provider wraps the libdns Route53 provider.
+// route53API is the part of the Route53 client used by Route53Provider.
+type route53API interface {
+	ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+}
+
+// Route53Provider updates records through the Route53 API.
 type Route53Provider struct {
-	provider *route53.Provider
+	client       route53API
+	hostedZoneID string // used for every zone if set
+
+	mu      sync.Mutex
+	zoneIDs map[string]string
+	options map[string]Route53RecordOptions // by record name
 }
 
-// NewRoute53Provider creates a new Route53 DNS provider.
+// Route53RecordOptions are Route53-specific settings of a record.
+type Route53RecordOptions struct {
+	// SetIdentifier selects one record set of a weighted, failover,
+	// latency or other routing policy. The record set must exist; its
+	// routing policy and health check are kept on updates.
+	SetIdentifier string
+}
+
+// NewRoute53Provider creates a new Route53 DNS provider. See loadAWSConfig
+// for how it obtains credentials.
 func NewRoute53Provider(config Config) (*Route53Provider, error) {
-	provider := &route53.Provider{
-		AccessKeyId:     config.AWSAccessKeyID,
-		SecretAccessKey: config.AWSSecretAccessKey,
-		Region:          config.AWSRegion,
+	cfg, err := loadAWSConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newRoute53Provider(route53.NewFromConfig(cfg))
+	p.hostedZoneID = config.AWSHostedZoneID
+	return p, nil
+}
+
+// defaultRoleSessionName names the sessions of assumed roles unless
+// AWS_ROLE_SESSION_NAME is set.
+const defaultRoleSessionName = "dns-updater"
+
+// loadAWSConfig loads the AWS configuration. Credentials are taken from the
+// static keys if set, and otherwise from the named profile or the standard
+// chain: environment variables, the shared config and credentials files
+// (including SSO), web identity tokens (IRSA), and the ECS or EC2 instance
+// metadata. If AWS_ROLE_ARN is set, these credentials, or the web identity
+// token in AWS_WEB_IDENTITY_TOKEN_FILE, are exchanged for the role's.
+func loadAWSConfig(ctx context.Context, config Config) (aws.Config, error) {
+	if (config.AWSAccessKeyID == "") != (config.AWSSecretAccessKey == "") {
+		return aws.Config{}, fmt.Errorf("Route53 provider requires both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if config.AWSAccessKeyID != "" && config.AWSProfile != "" {
+		return aws.Config{}, fmt.Errorf("Route53 provider accepts either static AWS keys or AWS_PROFILE, not both")
+	}
+	if config.AWSRoleARN == "" && (config.AWSExternalID != "" || config.AWSRoleSessionName != "" || config.AWSWebIdentityTokenFile != "") {
+		return aws.Config{}, fmt.Errorf("Route53 provider requires AWS_ROLE_ARN to assume a role")
 	}
-	
+
+	// Route53 is a global service; any region works for signing.
+	region := config.AWSRegion
+	if region == "" {
+		region = "us-east-1"
+	}
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+	if config.AWSAccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AWSAccessKeyID, config.AWSSecretAccessKey, ""),
+		))
+	}
+	if config.AWSProfile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(config.AWSProfile))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if config.AWSRoleARN != "" {
+		cfg.Credentials = roleCredentials(cfg, config)
+	}
+	return cfg, nil
+}
+
+// roleCredentials returns the credentials of the role AWS_ROLE_ARN,
+// obtained from STS with the web identity token if configured and with the
+// credentials of cfg otherwise.
+func roleCredentials(cfg aws.Config, config Config) aws.CredentialsProvider {
+	client := sts.NewFromConfig(cfg)
+	sessionName := config.AWSRoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	if config.AWSWebIdentityTokenFile != "" {
+		return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(client, config.AWSRoleARN,
+			stscreds.IdentityTokenFile(config.AWSWebIdentityTokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = sessionName
+			}))
+	}
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, config.AWSRoleARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if config.AWSExternalID != "" {
+				o.ExternalID = aws.String(config.AWSExternalID)
+			}
+		}))
+}
+
+func newRoute53Provider(client route53API) *Route53Provider {
 	return &Route53Provider{
-		provider: provider,
-	}, nil
+		client:  client,
+		zoneIDs: make(map[string]string),
+		options: make(map[string]Route53RecordOptions),
+	}
+}
+
+// SetRecordOptions sets the options of the record with the given fully
+// qualified name.
+func (r *Route53Provider) SetRecordOptions(name string, options Route53RecordOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.options[recordKey(name)] = options
+}
+
+// setIdentifier returns the set identifier of the record with the given
+// fully qualified name, or "" for a simple record set.
+func (r *Route53Provider) setIdentifier(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.options[recordKey(name)].SetIdentifier
+}
+
+// UpdateRecord updates an A or AAAA record using the Route53 provider.
+func (r *Route53Provider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	return r.UpdateRecords(ctx, zone, []Record{{Name: name, Type: recordType, IP: ip, TTL: ttl}})[0]
+}
+
+// UpdateRecords upserts records in a single change batch.
+func (r *Route53Provider) UpdateRecords(ctx context.Context, zone string, records []Record) []error {
+	errs := make([]error, len(records))
+	var changes []types.Change
+	var indexes []int
+	for i, rec := range records {
+		change, err := route53Change(zone, rec)
+		if err != nil {
+			errs[i] = Permanent(err)
+			continue
+		}
+		changes = append(changes, change)
+		indexes = append(indexes, i)
+	}
+	if len(changes) == 0 {
+		return errs
+	}
+
+	zoneID, err := r.zoneID(ctx, zone)
+	if err != nil {
+		for _, i := range indexes {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	var batch []types.Change
+	var batchIndexes []int
+	for j, i := range indexes {
+		if err := r.keepRoutingPolicy(ctx, zoneID, changes[j].ResourceRecordSet); err != nil {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, changes[j])
+		batchIndexes = append(batchIndexes, i)
+	}
+	if len(batch) == 0 {
+		return errs
+	}
+	return applyBatch(batch, batchIndexes, errs, func(batch []types.Change) error {
+		return r.change(ctx, zoneID, batch)
+	}, isInvalidChangeBatch)
 }
 
-// UpdateARecord updates an A record using the Route53 provider.
-func (r *Route53Provider) UpdateARecord(ctx context.Context, zone, name, ip string, ttl time.Duration) error {
-	record, err := createRecord(name, zone, ip, ttl)
+// keepRoutingPolicy turns set into an update of the record set selected by
+// the set identifier of the record, keeping its routing policy and health
+// check. Simple record sets are left as they are.
+func (r *Route53Provider) keepRoutingPolicy(ctx context.Context, zoneID string, set *types.ResourceRecordSet) error {
+	name := aws.ToString(set.Name)
+	identifier := r.setIdentifier(name)
+	if identifier == "" {
+		return nil
+	}
+
+	existing, err := r.findRecordSet(ctx, zoneID, name, string(set.Type), identifier)
 	if err != nil {
 		return err
 	}
-	
-	normalizedZone := normalizeZone(zone)
-	
-	// Use SetRecords to upsert the record
-	_, err = r.provider.SetRecords(ctx, normalizedZone, []libdns.Record{record})
-	return err
-}
\ No newline at end of file
+	if existing == nil {
+		return Permanent(fmt.Errorf("%s record set %s with set identifier %q not found", set.Type, name, identifier))
+	}
+	if existing.AliasTarget != nil || existing.TrafficPolicyInstanceId != nil {
+		return Permanent(fmt.Errorf("%s record set %s with set identifier %q is an alias or managed by a traffic policy", set.Type, name, identifier))
+	}
+
+	updated := *existing
+	updated.TTL = set.TTL
+	updated.ResourceRecords = set.ResourceRecords
+	*set = updated
+	return nil
+}
+
+// GetRecords returns the addresses of the record set with the given name
+// and type.
+func (r *Route53Provider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if err := validateRecordType(recordType); err != nil {
+		return nil, Permanent(err)
+	}
+	fqdn := absoluteName(name, zone)
+
+	zoneID, err := r.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := r.findRecordSet(ctx, zoneID, fqdn, recordType, r.setIdentifier(fqdn))
+	if err != nil || set == nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, rr := range set.ResourceRecords {
+		addrs = append(addrs, aws.ToString(rr.Value))
+	}
+	return addrs, nil
+}
+
+// findRecordSet returns the record set with the given name, type and set
+// identifier, or nil if there is none.
+func (r *Route53Provider) findRecordSet(ctx context.Context, zoneID, fqdn, recordType, identifier string) (*types.ResourceRecordSet, error) {
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(route53Escape(fqdn)),
+		StartRecordType: types.RRType(recordType),
+		MaxItems:        aws.Int32(1),
+	}
+	if identifier != "" {
+		input.StartRecordIdentifier = aws.String(identifier)
+	}
+	out, err := r.client.ListResourceRecordSets(ctx, input)
+	if err != nil {
+		return nil, classifyRoute53Error(err)
+	}
+
+	for _, set := range out.ResourceRecordSets {
+		if strings.EqualFold(route53Unescape(aws.ToString(set.Name)), fqdn) && string(set.Type) == recordType && aws.ToString(set.SetIdentifier) == identifier {
+			return &set, nil
+		}
+	}
+	return nil, nil
+}
+
+// route53Change builds the UPSERT change for rec.
+func route53Change(zone string, rec Record) (types.Change, error) {
+	record, err := createRecord(rec.Name, zone, rec.Type, rec.IP, rec.TTL)
+	if err != nil {
+		return types.Change{}, err
+	}
+
+	return types.Change{
+		Action: types.ChangeActionUpsert,
+		ResourceRecordSet: &types.ResourceRecordSet{
+			Name:            aws.String(absoluteName(rec.Name, zone)),
+			Type:            types.RRType(record.Type),
+			TTL:             aws.Int64(int64(record.TTL.Seconds())),
+			ResourceRecords: []types.ResourceRecord{{Value: aws.String(record.Value)}},
+		},
+	}, nil
+}
+
+// change submits changes to the hosted zone as one change batch.
+func (r *Route53Provider) change(ctx context.Context, zoneID string, changes []types.Change) error {
+	_, err := r.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch:  &types.ChangeBatch{Changes: changes},
+	})
+	return classifyRoute53Error(err)
+}
+
+// zoneID returns the ID of the hosted zone named zone. If both a public
+// and a private zone have that name, the public one is used unless the
+// hosted zone ID is configured.
+func (r *Route53Provider) zoneID(ctx context.Context, zone string) (string, error) {
+	if r.hostedZoneID != "" {
+		return r.hostedZoneID, nil
+	}
+	zone = normalizeZone(zone)
+
+	r.mu.Lock()
+	id, ok := r.zoneIDs[zone]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	out, err := r.client.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(zone),
+	})
+	if err != nil {
+		return "", classifyRoute53Error(err)
+	}
+
+	for _, z := range out.HostedZones {
+		if aws.ToString(z.Name) != zone {
+			continue
+		}
+		private := z.Config != nil && z.Config.PrivateZone
+		if id == "" || !private {
+			id = aws.ToString(z.Id)
+		}
+		if !private {
+			break
+		}
+	}
+	if id == "" {
+		return "", Permanent(fmt.Errorf("hosted zone %s not found", zone))
+	}
+
+	r.mu.Lock()
+	r.zoneIDs[zone] = id
+	r.mu.Unlock()
+	return id, nil
+}
+
+// route53Escape escapes the wildcard label of name the way Route53 stores
+// and orders it, as "\052".
+func route53Escape(name string) string {
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		return `\052.` + rest
+	}
+	return name
+}
+
+// route53Unescape reverses route53Escape on names returned by Route53.
+func route53Unescape(name string) string {
+	if rest, ok := strings.CutPrefix(name, `\052.`); ok {
+		return "*." + rest
+	}
+	return name
+}
+
+// isInvalidChangeBatch reports whether Route53 rejected a change batch
+// because of its contents.
+func isInvalidChangeBatch(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidChangeBatch"
+}
@@ -0,0 +1,425 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// fakeRoute53 records change batches and fails those containing a record
+// listed in invalid.
+type fakeRoute53 struct {
+	zones   []types.HostedZone
+	sets    []types.ResourceRecordSet
+	invalid map[string]bool
+	err     error
+	batches [][]types.Change
+	lookups int
+}
+
+func (f *fakeRoute53) ListHostedZonesByName(ctx context.Context, params *route53.ListHostedZonesByNameInput, optFns ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error) {
+	f.lookups++
+	return &route53.ListHostedZonesByNameOutput{HostedZones: f.zones}, nil
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	f.batches = append(f.batches, params.ChangeBatch.Changes)
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, c := range params.ChangeBatch.Changes {
+		if f.invalid[aws.ToString(c.ResourceRecordSet.Name)] {
+			return nil, &types.InvalidChangeBatch{Message: aws.String("invalid change")}
+		}
+	}
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+// ListResourceRecordSets returns the set matching the start name and type,
+// or like Route53 the set that follows it, here simply the first one.
+func (f *fakeRoute53) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	for _, set := range f.sets {
+		if aws.ToString(set.Name) == aws.ToString(params.StartRecordName) && set.Type == params.StartRecordType &&
+			aws.ToString(set.SetIdentifier) == aws.ToString(params.StartRecordIdentifier) {
+			return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: []types.ResourceRecordSet{set}}, nil
+		}
+	}
+	return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: f.sets[:min(1, len(f.sets))]}, nil
+}
+
+func hostedZone(id, name string, private bool) types.HostedZone {
+	return types.HostedZone{
+		Id:     aws.String(id),
+		Name:   aws.String(name),
+		Config: &types.HostedZoneConfig{PrivateZone: private},
+	}
+}
+
+func TestRoute53Provider_UpdateRecordsSingleBatch(t *testing.T) {
+	fake := &fakeRoute53{zones: []types.HostedZone{
+		hostedZone("/hostedzone/PRIVATE", "example.com.", true),
+		hostedZone("/hostedzone/PUBLIC", "example.com.", false),
+	}}
+	p := newRoute53Provider(fake)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "foo.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bar", Type: RecordTypeAAAA, IP: "2001:db8::1", TTL: 5 * time.Minute},
+		{Name: "baz.example.com", Type: RecordTypeA, IP: "not-an-ip", TTL: time.Minute},
+	})
+
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !IsPermanent(errs[2]) {
+		t.Errorf("expected permanent error for invalid address, got %v", errs[2])
+	}
+	if len(fake.batches) != 1 {
+		t.Fatalf("expected 1 change batch, got %d", len(fake.batches))
+	}
+
+	changes := fake.batches[0]
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	first := changes[0].ResourceRecordSet
+	if aws.ToString(first.Name) != "foo.example.com." || first.Type != types.RRTypeA || aws.ToInt64(first.TTL) != 60 {
+		t.Errorf("unexpected change %+v", first)
+	}
+	second := changes[1].ResourceRecordSet
+	if aws.ToString(second.Name) != "bar.example.com." || aws.ToString(second.ResourceRecords[0].Value) != "2001:db8::1" {
+		t.Errorf("unexpected change %+v", second)
+	}
+
+	// The public zone is preferred and the zone ID is cached.
+	p.UpdateRecords(context.Background(), "example.com.", []Record{{Name: "foo", Type: RecordTypeA, IP: "203.0.113.2"}})
+	if fake.lookups != 1 {
+		t.Errorf("expected zone lookup to be cached, got %d lookups", fake.lookups)
+	}
+	if id := p.zoneIDs["example.com."]; id != "/hostedzone/PUBLIC" {
+		t.Errorf("expected public zone, got %s", id)
+	}
+}
+
+func TestRoute53Provider_UpdateRecordsAttributesErrors(t *testing.T) {
+	fake := &fakeRoute53{
+		zones:   []types.HostedZone{hostedZone("/hostedzone/Z1", "example.com.", false)},
+		invalid: map[string]bool{"bad.example.com.": true},
+	}
+	p := newRoute53Provider(fake)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "good.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "bad.example.com", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+
+	if errs[0] != nil {
+		t.Errorf("expected good record to be updated, got %v", errs[0])
+	}
+	if !IsPermanent(errs[1]) {
+		t.Errorf("expected permanent error for bad record, got %v", errs[1])
+	}
+	// One failed batch followed by one batch per record.
+	if len(fake.batches) != 3 {
+		t.Errorf("expected 3 change batches, got %d", len(fake.batches))
+	}
+}
+
+func TestRoute53Provider_UpdateRecordsSharedError(t *testing.T) {
+	errThrottled := &types.ThrottlingException{Message: aws.String("slow down")}
+	fake := &fakeRoute53{
+		zones: []types.HostedZone{hostedZone("/hostedzone/Z1", "example.com.", false)},
+		err:   errThrottled,
+	}
+	p := newRoute53Provider(fake)
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "a.example.com", Type: RecordTypeA, IP: "203.0.113.1"},
+		{Name: "b.example.com", Type: RecordTypeA, IP: "203.0.113.1"},
+	})
+
+	for i, err := range errs {
+		if !IsThrottled(err) || !errors.Is(err, errThrottled) {
+			t.Errorf("record %d: expected throttling error, got %v", i, err)
+		}
+	}
+	if len(fake.batches) != 1 {
+		t.Errorf("expected 1 change batch, got %d", len(fake.batches))
+	}
+}
+
+func TestRoute53Provider_ZoneNotFound(t *testing.T) {
+	fake := &fakeRoute53{zones: []types.HostedZone{hostedZone("/hostedzone/Z1", "example.org.", false)}}
+	p := newRoute53Provider(fake)
+
+	err := p.UpdateRecord(context.Background(), "example.com", "foo.example.com", RecordTypeA, "203.0.113.1", time.Minute)
+	if !IsPermanent(err) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+	if len(fake.batches) != 0 {
+		t.Errorf("expected no change batch, got %d", len(fake.batches))
+	}
+}
+
+func TestRoute53Provider_HostedZoneID(t *testing.T) {
+	fake := &fakeRoute53{zones: []types.HostedZone{
+		hostedZone("/hostedzone/PRIVATE", "example.com.", true),
+		hostedZone("/hostedzone/PUBLIC", "example.com.", false),
+	}}
+	p := newRoute53Provider(fake)
+	p.hostedZoneID = "PRIVATE"
+
+	if err := p.UpdateRecord(context.Background(), "example.com", "foo", RecordTypeA, "192.0.2.1", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.lookups != 0 {
+		t.Errorf("expected no zone lookup, got %d lookups", fake.lookups)
+	}
+}
+
+func TestRoute53Provider_SetIdentifier(t *testing.T) {
+	primary := types.ResourceRecordSet{
+		Name:            aws.String("home.example.com."),
+		Type:            types.RRTypeA,
+		SetIdentifier:   aws.String("home"),
+		Failover:        types.ResourceRecordSetFailoverPrimary,
+		HealthCheckId:   aws.String("hc-1"),
+		TTL:             aws.Int64(300),
+		ResourceRecords: []types.ResourceRecord{{Value: aws.String("198.51.100.1")}},
+	}
+	secondary := types.ResourceRecordSet{
+		Name:            aws.String("home.example.com."),
+		Type:            types.RRTypeA,
+		SetIdentifier:   aws.String("cloud"),
+		Failover:        types.ResourceRecordSetFailoverSecondary,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []types.ResourceRecord{{Value: aws.String("198.51.100.2")}},
+	}
+	fake := &fakeRoute53{
+		zones: []types.HostedZone{hostedZone("/hostedzone/Z1", "example.com.", false)},
+		sets:  []types.ResourceRecordSet{secondary, primary},
+	}
+	p := newRoute53Provider(fake)
+	p.SetRecordOptions("home.example.com", Route53RecordOptions{SetIdentifier: "home"})
+	p.SetRecordOptions("missing.example.com", Route53RecordOptions{SetIdentifier: "home"})
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "home", RecordTypeA)
+	if err != nil || len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v, %v", addrs, err)
+	}
+
+	errs := p.UpdateRecords(context.Background(), "example.com", []Record{
+		{Name: "home", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+		{Name: "missing", Type: RecordTypeA, IP: "203.0.113.1", TTL: time.Minute},
+	})
+	if errs[0] != nil {
+		t.Fatalf("unexpected error: %v", errs[0])
+	}
+	if !IsPermanent(errs[1]) {
+		t.Errorf("expected permanent error for missing record set, got %v", errs[1])
+	}
+	if len(fake.batches) != 1 || len(fake.batches[0]) != 1 {
+		t.Fatalf("expected 1 change batch with 1 change, got %v", fake.batches)
+	}
+	set := fake.batches[0][0].ResourceRecordSet
+	if aws.ToString(set.SetIdentifier) != "home" || set.Failover != types.ResourceRecordSetFailoverPrimary || aws.ToString(set.HealthCheckId) != "hc-1" {
+		t.Errorf("expected routing policy to be kept, got %+v", set)
+	}
+	if aws.ToInt64(set.TTL) != 60 || aws.ToString(set.ResourceRecords[0].Value) != "203.0.113.1" {
+		t.Errorf("expected new address and TTL, got %+v", set)
+	}
+}
+
+func TestRoute53Provider_GetRecords(t *testing.T) {
+	fake := &fakeRoute53{
+		zones: []types.HostedZone{hostedZone("/hostedzone/Z1", "example.com.", false)},
+		sets: []types.ResourceRecordSet{
+			{
+				Name:            aws.String("other.example.com."),
+				Type:            types.RRTypeA,
+				ResourceRecords: []types.ResourceRecord{{Value: aws.String("198.51.100.9")}},
+			},
+			{
+				Name:            aws.String("foo.example.com."),
+				Type:            types.RRTypeA,
+				ResourceRecords: []types.ResourceRecord{{Value: aws.String("198.51.100.1")}},
+			},
+		},
+	}
+	p := newRoute53Provider(fake)
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "foo.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v", addrs)
+	}
+
+	addrs, err = p.GetRecords(context.Background(), "example.com", "missing.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 0 {
+		t.Errorf("expected no addresses for missing record, got %v", addrs)
+	}
+
+	if _, err := p.GetRecords(context.Background(), "example.com", "foo", "MX"); !IsPermanent(err) {
+		t.Errorf("expected permanent error for unsupported type, got %v", err)
+	}
+}
+
+func TestRoute53Provider_GetRecordsWildcard(t *testing.T) {
+	fake := &fakeRoute53{
+		zones: []types.HostedZone{hostedZone("/hostedzone/Z1", "example.com.", false)},
+		sets: []types.ResourceRecordSet{{
+			Name:            aws.String(`\052.home.example.com.`),
+			Type:            types.RRTypeA,
+			ResourceRecords: []types.ResourceRecord{{Value: aws.String("198.51.100.1")}},
+		}},
+	}
+	p := newRoute53Provider(fake)
+
+	addrs, err := p.GetRecords(context.Background(), "example.com", "*.home.example.com", RecordTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v", addrs)
+	}
+}
+
+func TestLoadAWSConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "access key without secret", config: Config{AWSAccessKeyID: "AKID"}},
+		{name: "static keys and profile", config: Config{AWSAccessKeyID: "AKID", AWSSecretAccessKey: "secret", AWSProfile: "sso"}},
+		{name: "external ID without role", config: Config{AWSExternalID: "ext"}},
+		{name: "web identity without role", config: Config{AWSWebIdentityTokenFile: "/var/run/token"}},
+		{name: "unknown profile", config: Config{AWSProfile: "does-not-exist"}},
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadAWSConfig(context.Background(), tt.config); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestLoadAWSConfigProfile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "credentials"), []byte("[home]\naws_access_key_id = AKIDHOME\naws_secret_access_key = secret\n"), 0o600)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	cfg, err := loadAWSConfig(context.Background(), Config{AWSProfile: "home"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil || creds.AccessKeyID != "AKIDHOME" {
+		t.Errorf("expected credentials of profile, got %+v, %v", creds, err)
+	}
+}
+
+// fakeSTS answers AssumeRole and AssumeRoleWithWebIdentity requests with
+// temporary credentials and records their parameters.
+func fakeSTS(t *testing.T, params *url.Values) aws.Config {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*params = r.PostForm
+		action := r.PostForm.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIAROLE</AccessKeyId>
+      <SecretAccessKey>role-secret</SecretAccessKey>
+      <SessionToken>role-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</%[1]sResponse>`, action)
+	}))
+	t.Cleanup(srv.Close)
+
+	return aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "secret", ""),
+		BaseEndpoint: aws.String(srv.URL),
+		HTTPClient:   srv.Client(),
+	}
+}
+
+func TestRoleCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("web-identity-token"), 0o600)
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected url.Values
+	}{
+		{
+			name:   "assume role",
+			config: Config{AWSRoleARN: "arn:aws:iam::123456789012:role/dns", AWSExternalID: "ext", AWSRoleSessionName: "laptop"},
+			expected: url.Values{
+				"Action":          {"AssumeRole"},
+				"RoleArn":         {"arn:aws:iam::123456789012:role/dns"},
+				"ExternalId":      {"ext"},
+				"RoleSessionName": {"laptop"},
+			},
+		},
+		{
+			name:   "web identity",
+			config: Config{AWSRoleARN: "arn:aws:iam::123456789012:role/dns", AWSWebIdentityTokenFile: tokenFile},
+			expected: url.Values{
+				"Action":           {"AssumeRoleWithWebIdentity"},
+				"RoleArn":          {"arn:aws:iam::123456789012:role/dns"},
+				"RoleSessionName":  {defaultRoleSessionName},
+				"WebIdentityToken": {"web-identity-token"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params url.Values
+			cfg := fakeSTS(t, &params)
+
+			creds, err := roleCredentials(cfg, tt.config).Retrieve(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if creds.AccessKeyID != "ASIAROLE" || creds.SessionToken != "role-token" {
+				t.Errorf("expected credentials of the role, got %+v", creds)
+			}
+			for key, values := range tt.expected {
+				if params.Get(key) != values[0] {
+					t.Errorf("expected %s=%s, got %q", key, values[0], params.Get(key))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Package gateway discovers the public IPv4 address by asking the local
+// router, using UPnP IGD or NAT-PMP/PCP, instead of a third-party service.
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// routePath is the procfs file listing the IPv4 routing table.
+var routePath = "/proc/net/route"
+
+// DefaultGateway returns the address of the IPv4 default gateway.
+func DefaultGateway() (net.IP, error) {
+	f, err := os.Open(routePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("cannot determine default gateway on this system; set gateway explicitly")
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != net.IPv4len {
+			continue
+		}
+		// The kernel prints the address in host byte order.
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.NativeEndian.Uint32(raw))
+		if ip.IsUnspecified() {
+			continue
+		}
+		return ip, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no IPv4 default route found")
+}
+
+// resolveGateway returns addr with the given port, defaulting the host to the
+// IPv4 default gateway when addr is empty.
+func resolveGateway(addr string, port int) (string, error) {
+	if addr == "" {
+		ip, err := DefaultGateway()
+		if err != nil {
+			return "", err
+		}
+		return net.JoinHostPort(ip.String(), fmt.Sprint(port)), nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, fmt.Sprint(port)), nil
+	}
+	return addr, nil
+}
+
+// validateExternalIP checks that the gateway reported a usable address.
+func validateExternalIP(ip net.IP) (string, error) {
+	if ip == nil || ip.IsUnspecified() {
+		return "", errors.New("gateway reported no external address")
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// routeHex formats an IPv4 address the way /proc/net/route prints it.
+func routeHex(ip string) string {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, binary.BigEndian.Uint32(net.ParseIP(ip).To4()))
+	return hex.EncodeToString(b)
+}
+
+func TestDefaultGateway(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t" + routeHex("192.168.1.0") + "\t00000000\t0001\t0\t0\t0\t" + routeHex("255.255.255.0") + "\t0\t0\t0\n" +
+		"eth0\t00000000\t" + routeHex("192.168.1.1") + "\t0003\t0\t0\t0\t00000000\t0\t0\t0\n"
+
+	path := filepath.Join(t.TempDir(), "route")
+	if err := os.WriteFile(path, []byte(table), 0600); err != nil {
+		t.Fatalf("failed to write route table: %v", err)
+	}
+	defer func(old string) { routePath = old }(routePath)
+	routePath = path
+
+	ip, err := DefaultGateway()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip.String() != "192.168.1.1" {
+		t.Errorf("expected 192.168.1.1, got %s", ip)
+	}
+}
+
+func TestDefaultGatewayMissing(t *testing.T) {
+	defer func(old string) { routePath = old }(routePath)
+
+	routePath = filepath.Join(t.TempDir(), "route")
+	if err := os.WriteFile(routePath, []byte("Iface\tDestination\tGateway\n"), 0600); err != nil {
+		t.Fatalf("failed to write route table: %v", err)
+	}
+	if _, err := DefaultGateway(); err == nil {
+		t.Error("expected error without a default route")
+	}
+
+	routePath = filepath.Join(t.TempDir(), "missing")
+	if _, err := DefaultGateway(); err == nil {
+		t.Error("expected error without procfs")
+	}
+}
+
+func TestResolveGateway(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "192.168.1.1", expected: "192.168.1.1:5351"},
+		{addr: "192.168.1.1:15351", expected: "192.168.1.1:15351"},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveGateway(tt.addr, Port)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.expected {
+			t.Errorf("resolveGateway(%q): expected %s, got %s", tt.addr, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Port is the UDP port NAT-PMP and PCP servers listen on.
+const Port = 5351
+
+const (
+	natpmpVersion     = 0
+	natpmpOpExternal  = 0
+	pcpVersion        = 2
+	pcpOpMap          = 1
+	pcpResponseBit    = 0x80
+	resultUnsupported = 1
+
+	// initialRetransmit is the first retransmission interval from RFC 6886.
+	initialRetransmit = 250 * time.Millisecond
+)
+
+// ErrUnsupportedVersion is returned when the gateway does not speak the
+// requested protocol version, e.g. a PCP-only gateway asked via NAT-PMP.
+var ErrUnsupportedVersion = errors.New("gateway does not support the protocol version")
+
+// NATPMPClient implements the ipify.ClientInterface by asking the gateway
+// for its external address using NAT-PMP (RFC 6886), falling back to PCP
+// (RFC 6887) when the gateway only supports the newer protocol.
+type NATPMPClient struct {
+	gateway string
+	timeout time.Duration
+}
+
+// NewNATPMPClient returns a new NATPMPClient. An empty gateway uses the
+// IPv4 default gateway; a gateway without a port uses Port.
+func NewNATPMPClient(gateway string, timeout time.Duration) *NATPMPClient {
+	if timeout == 0 {
+		timeout = 4 * time.Second
+	}
+	return &NATPMPClient{
+		gateway: gateway,
+		timeout: timeout,
+	}
+}
+
+// GetIP fetches the gateway's external IPv4 address.
+func (c *NATPMPClient) GetIP(ctx context.Context) (string, error) {
+	addr, err := resolveGateway(c.gateway, Port)
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := c.natpmpExternalAddress(ctx, addr)
+	if errors.Is(err, ErrUnsupportedVersion) {
+		ip, err = c.pcpExternalAddress(ctx, addr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return validateExternalIP(ip)
+}
+
+// natpmpExternalAddress sends a NAT-PMP external address request.
+func (c *NATPMPClient) natpmpExternalAddress(ctx context.Context, addr string) (net.IP, error) {
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("NAT-PMP: %w", err)
+	}
+	defer conn.Close()
+
+	req := []byte{natpmpVersion, natpmpOpExternal}
+	resp, err := c.exchange(ctx, conn, req, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[1] == pcpResponseBit|natpmpOpExternal
+	})
+	if err != nil {
+		return nil, fmt.Errorf("NAT-PMP: %w", err)
+	}
+
+	result := binary.BigEndian.Uint16(resp[2:4])
+	if result == resultUnsupported {
+		return nil, ErrUnsupportedVersion
+	}
+	if result != 0 {
+		return nil, fmt.Errorf("NAT-PMP: gateway returned result code %d", result)
+	}
+	if len(resp) < 12 {
+		return nil, fmt.Errorf("NAT-PMP: short response (%d bytes)", len(resp))
+	}
+	return net.IP(resp[8:12]).To4(), nil
+}
+
+// pcpExternalAddress reads the external address from the response to a PCP
+// MAP request with a lifetime of zero. Such a request deletes the mapping
+// of the socket's own port, of which there is none, instead of creating
+// one, so learning the address opens no port on the gateway.
+func (c *NATPMPClient) pcpExternalAddress(ctx context.Context, addr string) (net.IP, error) {
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("PCP: %w", err)
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.UDPAddr)
+
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	req := make([]byte, 60)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	copy(req[8:24], local.IP.To16())
+	copy(req[24:36], nonce)
+	req[36] = 17 // UDP
+	binary.BigEndian.PutUint16(req[40:42], uint16(local.Port))
+	copy(req[44:60], net.IPv4zero.To16())
+
+	resp, err := c.exchange(ctx, conn, req, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[1] == pcpResponseBit|pcpOpMap
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PCP: %w", err)
+	}
+
+	if resp[3] != 0 {
+		return nil, fmt.Errorf("PCP: gateway returned result code %d", resp[3])
+	}
+	if len(resp) < 60 {
+		return nil, fmt.Errorf("PCP: short response (%d bytes)", len(resp))
+	}
+	if string(resp[24:36]) != string(nonce) {
+		return nil, errors.New("PCP: response nonce mismatch")
+	}
+	return net.IP(resp[44:60]), nil
+}
+
+// exchange sends req to the gateway conn is connected to, retransmitting
+// with a doubling interval until a response accepted by match arrives or the
+// timeout expires.
+func (c *NATPMPClient) exchange(ctx context.Context, conn net.Conn, req []byte, match func([]byte) bool) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1100)
+	for interval := initialRetransmit; ; interval *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		wait := time.Now().Add(interval)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		if err := conn.SetReadDeadline(wait); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if match(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("no response from gateway %s", conn.RemoteAddr())
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeGateway is an in-process NAT-PMP/PCP responder.
+type fakeGateway struct {
+	conn       *net.UDPConn
+	externalIP net.IP
+	pcpOnly    bool
+	result     byte
+	requests   chan []byte
+	ports      chan int // source port of each request
+}
+
+func newFakeGateway(t *testing.T, externalIP string, pcpOnly bool, result byte) *fakeGateway {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	g := &fakeGateway{
+		conn:       conn,
+		externalIP: net.ParseIP(externalIP),
+		pcpOnly:    pcpOnly,
+		result:     result,
+		requests:   make(chan []byte, 10),
+		ports:      make(chan int, 10),
+	}
+	t.Cleanup(func() { conn.Close() })
+	go g.serve()
+	return g
+}
+
+func (g *fakeGateway) addr() string {
+	return g.conn.LocalAddr().String()
+}
+
+func (g *fakeGateway) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, raddr, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := append([]byte(nil), buf[:n]...)
+		g.requests <- req
+		g.ports <- raddr.Port
+
+		var resp []byte
+		switch req[0] {
+		case natpmpVersion:
+			resp = make([]byte, 12)
+			resp[1] = pcpResponseBit | req[1]
+			if g.pcpOnly {
+				binary.BigEndian.PutUint16(resp[2:4], resultUnsupported)
+				resp = resp[:8]
+			} else {
+				resp[3] = g.result
+				copy(resp[8:12], g.externalIP.To4())
+			}
+		case pcpVersion:
+			resp = make([]byte, 60)
+			resp[0] = pcpVersion
+			resp[1] = pcpResponseBit | req[1]
+			resp[3] = g.result
+			copy(resp[4:8], req[4:8])
+			copy(resp[24:60], req[24:60])
+			copy(resp[44:60], g.externalIP.To16())
+		}
+		_, _ = g.conn.WriteToUDP(resp, raddr)
+	}
+}
+
+func TestNATPMPClientGetIP(t *testing.T) {
+	g := newFakeGateway(t, "203.0.113.44", false, 0)
+
+	c := NewNATPMPClient(g.addr(), time.Second)
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.44" {
+		t.Errorf("expected 203.0.113.44, got %s", ip)
+	}
+
+	req := <-g.requests
+	if len(req) != 2 || req[0] != natpmpVersion || req[1] != natpmpOpExternal {
+		t.Errorf("unexpected NAT-PMP request %v", req)
+	}
+}
+
+func TestNATPMPClientFallsBackToPCP(t *testing.T) {
+	g := newFakeGateway(t, "198.51.100.20", true, 0)
+
+	c := NewNATPMPClient(g.addr(), time.Second)
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "198.51.100.20" {
+		t.Errorf("expected 198.51.100.20, got %s", ip)
+	}
+
+	<-g.requests // NAT-PMP attempt
+	<-g.ports
+	req := <-g.requests
+	if len(req) != 60 || req[0] != pcpVersion || req[1] != pcpOpMap {
+		t.Fatalf("unexpected PCP request %v", req)
+	}
+	if lifetime := binary.BigEndian.Uint32(req[4:8]); lifetime != 0 {
+		t.Errorf("expected lifetime 0 so no mapping is created, got %d", lifetime)
+	}
+	if port, internal := <-g.ports, int(binary.BigEndian.Uint16(req[40:42])); internal != port {
+		t.Errorf("expected the internal port %d of the sending socket, got %d", port, internal)
+	}
+}
+
+func TestNATPMPClientResultCode(t *testing.T) {
+	g := newFakeGateway(t, "203.0.113.44", false, 3) // network failure
+
+	c := NewNATPMPClient(g.addr(), time.Second)
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error for non-zero result code")
+	}
+}
+
+func TestNATPMPClientNoExternalAddress(t *testing.T) {
+	g := newFakeGateway(t, "0.0.0.0", false, 0)
+
+	c := NewNATPMPClient(g.addr(), time.Second)
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error for unspecified external address")
+	}
+}
+
+func TestNATPMPClientTimeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	c := NewNATPMPClient(conn.LocalAddr().String(), 300*time.Millisecond)
+	start := time.Now()
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout not honored, took %v", elapsed)
+	}
+}
@@ -0,0 +1,243 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ssdpAddr is the multicast address UPnP devices listen on for discovery.
+const ssdpAddr = "239.255.255.250:1900"
+
+// wanServiceTypes lists the IGD services able to report the external address,
+// in order of preference.
+var wanServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// UPnPClient implements the ipify.ClientInterface by calling the
+// GetExternalIPAddress action of a UPnP Internet Gateway Device.
+type UPnPClient struct {
+	httpClient *http.Client
+	ssdpAddr   string
+	location   string
+	timeout    time.Duration
+}
+
+// NewUPnPClient returns a new UPnPClient. If location is empty, the gateway
+// is discovered with SSDP; otherwise location is the URL of its device
+// description. If httpClient is nil, http.DefaultClient is used.
+func NewUPnPClient(httpClient *http.Client, location string, timeout time.Duration) *UPnPClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+	return &UPnPClient{
+		httpClient: httpClient,
+		ssdpAddr:   ssdpAddr,
+		location:   location,
+		timeout:    timeout,
+	}
+}
+
+// GetIP fetches the gateway's external IPv4 address.
+func (c *UPnPClient) GetIP(ctx context.Context) (string, error) {
+	location := c.location
+	if location == "" {
+		var err error
+		location, err = c.discover(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	controlURL, serviceType, err := c.findWANService(ctx, location)
+	if err != nil {
+		return "", fmt.Errorf("UPnP: %w", err)
+	}
+	ip, err := c.getExternalIPAddress(ctx, controlURL, serviceType)
+	if err != nil {
+		return "", fmt.Errorf("UPnP: %w", err)
+	}
+	return validateExternalIP(net.ParseIP(ip))
+}
+
+// discover sends an SSDP M-SEARCH for Internet Gateway Devices and returns
+// the description URL of the first device that answers.
+func (c *UPnPClient) discover(ctx context.Context) (string, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", c.ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(req), raddr); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", errors.New("UPnP: no Internet Gateway Device found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// upnpDevice is the subset of a UPnP device description needed to find the
+// WAN connection service.
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+// findWANService fetches the device description and returns the absolute
+// control URL and type of its WAN connection service.
+func (c *UPnPClient) findWANService(ctx context.Context, location string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("device description: unexpected status: %s", resp.Status)
+	}
+
+	var root upnpRoot
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root); err != nil {
+		return "", "", fmt.Errorf("device description: %w", err)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", "", err
+	}
+	if root.URLBase != "" {
+		if b, err := url.Parse(root.URLBase); err == nil {
+			base = b
+		}
+	}
+
+	for _, serviceType := range wanServiceTypes {
+		if controlURL := findControlURL(root.Device, serviceType); controlURL != "" {
+			ref, err := url.Parse(controlURL)
+			if err != nil {
+				return "", "", err
+			}
+			return base.ResolveReference(ref).String(), serviceType, nil
+		}
+	}
+	return "", "", fmt.Errorf("device at %s has no WAN connection service", location)
+}
+
+// findControlURL searches the device tree for a service of the given type.
+func findControlURL(d upnpDevice, serviceType string) string {
+	for _, s := range d.Services {
+		if strings.TrimSpace(s.ServiceType) == serviceType {
+			return strings.TrimSpace(s.ControlURL)
+		}
+	}
+	for _, child := range d.Devices {
+		if controlURL := findControlURL(child, serviceType); controlURL != "" {
+			return controlURL
+		}
+	}
+	return ""
+}
+
+// soapResponse is the envelope returned by GetExternalIPAddress.
+type soapResponse struct {
+	Body struct {
+		Response struct {
+			ExternalIPAddress string `xml:"NewExternalIPAddress"`
+		} `xml:"GetExternalIPAddressResponse"`
+		Fault *struct {
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// getExternalIPAddress invokes the GetExternalIPAddress SOAP action.
+func (c *UPnPClient) getExternalIPAddress(ctx context.Context, controlURL, serviceType string) (string, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"/></s:Body>` +
+		`</s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+`#GetExternalIPAddress"`)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var envelope soapResponse
+	decodeErr := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && envelope.Body.Fault != nil {
+			return "", fmt.Errorf("GetExternalIPAddress: %s", envelope.Body.Fault.String)
+		}
+		return "", fmt.Errorf("GetExternalIPAddress: unexpected status: %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("GetExternalIPAddress: %w", decodeErr)
+	}
+	return strings.TrimSpace(envelope.Body.Response.ExternalIPAddress), nil
+}
@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const igdDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const externalIPResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+      <NewExternalIPAddress>203.0.113.77</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`
+
+const faultResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <s:Fault>
+      <faultcode>s:Client</faultcode>
+      <faultstring>UPnPError</faultstring>
+    </s:Fault>
+  </s:Body>
+</s:Envelope>`
+
+// newFakeIGD starts an HTTP server serving the device description and the
+// WANIPConnection control endpoint.
+func newFakeIGD(t *testing.T, fault bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, igdDescription)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("SOAPAction"); got != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` {
+			t.Errorf("unexpected SOAPAction %s", got)
+		}
+		if fault {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, faultResponse)
+			return
+		}
+		_, _ = io.WriteString(w, externalIPResponse)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newFakeSSDP answers M-SEARCH requests with the given location.
+func newFakeSSDP(t *testing.T, location string) string {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, raddr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(string(buf[:n]), "M-SEARCH") {
+				continue
+			}
+			resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\nLOCATION: %s\r\n\r\n", location)
+			_, _ = conn.WriteToUDP([]byte(resp), raddr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUPnPClientDiscoversGateway(t *testing.T) {
+	igd := newFakeIGD(t, false)
+
+	c := NewUPnPClient(igd.Client(), "", time.Second)
+	c.ssdpAddr = newFakeSSDP(t, igd.URL+"/rootDesc.xml")
+
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.77" {
+		t.Errorf("expected 203.0.113.77, got %s", ip)
+	}
+}
+
+func TestUPnPClientExplicitLocation(t *testing.T) {
+	igd := newFakeIGD(t, false)
+
+	c := NewUPnPClient(igd.Client(), igd.URL+"/rootDesc.xml", time.Second)
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.77" {
+		t.Errorf("expected 203.0.113.77, got %s", ip)
+	}
+}
+
+func TestUPnPClientFault(t *testing.T) {
+	igd := newFakeIGD(t, true)
+
+	c := NewUPnPClient(igd.Client(), igd.URL+"/rootDesc.xml", time.Second)
+	_, err := c.GetIP(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "UPnPError") {
+		t.Fatalf("expected SOAP fault, got %v", err)
+	}
+}
+
+func TestUPnPClientNoGateway(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	c := NewUPnPClient(nil, "", 200*time.Millisecond)
+	c.ssdpAddr = conn.LocalAddr().String()
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error when no gateway answers")
+	}
+}
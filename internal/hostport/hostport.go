@@ -0,0 +1,17 @@
+// Package hostport handles the host:port addresses of servers given in the
+// configuration.
+package hostport
+
+import (
+	"net"
+	"strings"
+)
+
+// WithDefaultPort appends port to addr unless it already has one. IPv6
+// addresses may be given with or without brackets.
+func WithDefaultPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), port)
+}
@@ -0,0 +1,19 @@
+package hostport
+
+import "testing"
+
+func TestWithDefaultPort(t *testing.T) {
+	tests := map[string]string{
+		"ns1.example.net":      "ns1.example.net:53",
+		"ns1.example.net:5353": "ns1.example.net:5353",
+		"192.0.2.1":            "192.0.2.1:53",
+		"2001:db8::1":          "[2001:db8::1]:53",
+		"[2001:db8::1]":        "[2001:db8::1]:53",
+		"[2001:db8::1]:5353":   "[2001:db8::1]:5353",
+	}
+	for addr, expected := range tests {
+		if got := WithDefaultPort(addr, "53"); got != expected {
+			t.Errorf("WithDefaultPort(%q) = %q, expected %q", addr, got, expected)
+		}
+	}
+}
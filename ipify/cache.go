@@ -0,0 +1,95 @@
+package ipify
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedLookupTimeout bounds a lookup shared by several callers. It runs
+// without the deadline of the caller that started it, so a source that
+// hangs would otherwise block every caller joining it.
+const sharedLookupTimeout = 30 * time.Second
+
+// CachedClient wraps a ClientInterface so that concurrent lookups share a
+// single request and successful answers are reused for a short time. This
+// lets many records be reconciled in the same cycle with one lookup.
+type CachedClient struct {
+	client  ClientInterface
+	ttl     time.Duration
+	timeout time.Duration // of a shared lookup
+	group   singleflight.Group
+	now     func() time.Time
+
+	mu        sync.Mutex
+	ip        string
+	fetchedAt time.Time
+	// generation is incremented by Invalidate. Lookups started in an
+	// earlier generation are not cached or joined afterwards.
+	generation uint64
+}
+
+// NewCachedClient returns a CachedClient that reuses answers from client for
+// ttl. Errors are never cached.
+func NewCachedClient(client ClientInterface, ttl time.Duration) *CachedClient {
+	return &CachedClient{
+		client:  client,
+		ttl:     ttl,
+		timeout: sharedLookupTimeout,
+		now:     time.Now,
+	}
+}
+
+// GetIP returns the cached address if it is fresh, or joins an in-flight
+// lookup, or starts a new one.
+func (c *CachedClient) GetIP(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if c.ip != "" && c.now().Sub(c.fetchedAt) < c.ttl {
+		ip := c.ip
+		c.mu.Unlock()
+		return ip, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	// The shared lookup must not be cut short when the caller that happened
+	// to start it gives up, so it runs without the caller's cancellation,
+	// bounded by a timeout of its own instead.
+	ch := c.group.DoChan(strconv.FormatUint(generation, 10), func() (interface{}, error) {
+		lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+		ip, err := c.client.GetIP(lookupCtx)
+		if err != nil {
+			return "", err
+		}
+		c.mu.Lock()
+		if c.generation == generation {
+			c.ip, c.fetchedAt = ip, c.now()
+		}
+		c.mu.Unlock()
+		return ip, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate discards the cached address, so the next GetIP performs a new
+// lookup. A lookup still in flight is not cached when it completes. It is
+// used when the network is known to have changed.
+func (c *CachedClient) Invalidate() {
+	c.mu.Lock()
+	c.ip = ""
+	c.generation++
+	c.mu.Unlock()
+}
@@ -0,0 +1,164 @@
+package ipify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingClient struct {
+	calls   atomic.Int32
+	release chan struct{}
+	ip      string
+	err     error
+}
+
+func (c *countingClient) GetIP(ctx context.Context) (string, error) {
+	c.calls.Add(1)
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return c.ip, c.err
+}
+
+func TestCachedClientSharesConcurrentLookups(t *testing.T) {
+	inner := &countingClient{ip: "192.0.2.1", release: make(chan struct{})}
+	c := NewCachedClient(inner, time.Minute)
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ip, err := c.GetIP(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = ip
+		}(i)
+	}
+
+	// Give every goroutine the chance to join the in-flight lookup.
+	for inner.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if n := inner.calls.Load(); n != 1 {
+		t.Errorf("expected 1 lookup, got %d", n)
+	}
+	for _, ip := range results {
+		if ip != "192.0.2.1" {
+			t.Errorf("expected 192.0.2.1, got %q", ip)
+		}
+	}
+}
+
+func TestCachedClientExpiry(t *testing.T) {
+	inner := &countingClient{ip: "192.0.2.1"}
+	c := NewCachedClient(inner, time.Minute)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetIP(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := inner.calls.Load(); n != 1 {
+		t.Fatalf("expected 1 lookup while cached, got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.GetIP(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := inner.calls.Load(); n != 2 {
+		t.Fatalf("expected a new lookup after expiry, got %d", n)
+	}
+
+	c.Invalidate()
+	if _, err := c.GetIP(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := inner.calls.Load(); n != 3 {
+		t.Fatalf("expected a new lookup after invalidation, got %d", n)
+	}
+}
+
+func TestCachedClientDoesNotCacheErrors(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	inner := &countingClient{err: errLookup}
+	c := NewCachedClient(inner, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetIP(context.Background()); !errors.Is(err, errLookup) {
+			t.Fatalf("expected lookup error, got %v", err)
+		}
+	}
+	if n := inner.calls.Load(); n != 2 {
+		t.Errorf("expected every failed lookup to be retried, got %d calls", n)
+	}
+}
+
+func TestCachedClientCallerCancel(t *testing.T) {
+	inner := &countingClient{ip: "192.0.2.1", release: make(chan struct{})}
+	defer close(inner.release)
+	c := NewCachedClient(inner, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetIP(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCachedClientLookupTimeout(t *testing.T) {
+	inner := &countingClient{ip: "192.0.2.1", release: make(chan struct{})}
+	defer close(inner.release)
+	c := NewCachedClient(inner, time.Minute)
+	c.timeout = 10 * time.Millisecond
+
+	if _, err := c.GetIP(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the hung lookup to time out, got %v", err)
+	}
+}
+
+func TestCachedClientInvalidateDuringLookup(t *testing.T) {
+	inner := &countingClient{ip: "192.0.2.1", release: make(chan struct{})}
+	c := NewCachedClient(inner, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := c.GetIP(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	for inner.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The lookup in flight predates the network change, so its answer must
+	// not be reused.
+	c.Invalidate()
+	close(inner.release)
+	<-done
+
+	if _, err := c.GetIP(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := inner.calls.Load(); n != 2 {
+		t.Errorf("expected a new lookup after invalidation, got %d", n)
+	}
+}
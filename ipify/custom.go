@@ -0,0 +1,184 @@
+package ipify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxBodySize limits how much of a response body is read.
+const maxBodySize = 64 << 10
+
+// Extractor pulls the IP address out of a response body.
+type Extractor interface {
+	Extract(body []byte) (string, error)
+}
+
+// TextExtractor treats the whole body as the address, as returned by
+// services like icanhazip.com.
+type TextExtractor struct{}
+
+// Extract returns the trimmed body.
+func (TextExtractor) Extract(body []byte) (string, error) {
+	return strings.TrimSpace(string(body)), nil
+}
+
+// JSONExtractor reads the address from a field of a JSON body. The path is
+// a dot-separated list of object keys and array indexes, e.g. "ip" or
+// "data.interfaces.0.address".
+type JSONExtractor struct {
+	Path string
+}
+
+// Extract returns the string at the configured path.
+func (e JSONExtractor) Extract(body []byte) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", err
+	}
+
+	for _, key := range strings.Split(e.Path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("JSON path %q: key %q not found", e.Path, key)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("JSON path %q: invalid index %q", e.Path, key)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("JSON path %q: cannot descend into %q", e.Path, key)
+		}
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("JSON path %q: value is not a string", e.Path)
+	}
+	return s, nil
+}
+
+// RegexExtractor finds the address with a regular expression. The first
+// capture group is used if the expression has one, otherwise the whole
+// match.
+type RegexExtractor struct {
+	re *regexp.Regexp
+}
+
+// NewRegexExtractor compiles pattern into a RegexExtractor.
+func NewRegexExtractor(pattern string) (*RegexExtractor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexExtractor{re: re}, nil
+}
+
+// Extract returns the first match.
+func (e *RegexExtractor) Extract(body []byte) (string, error) {
+	m := e.re.FindSubmatch(body)
+	if m == nil {
+		return "", fmt.Errorf("pattern %q did not match", e.re.String())
+	}
+	if len(m) > 1 {
+		return string(m[1]), nil
+	}
+	return string(m[0]), nil
+}
+
+// Request describes the HTTP request a CustomClient sends.
+type Request struct {
+	Method   string
+	URL      string
+	Headers  map[string]string
+	Username string
+	Password string
+}
+
+// CustomClient implements the ClientInterface for arbitrary HTTP endpoints,
+// such as an internal "what is my IP" service or a router status page.
+type CustomClient struct {
+	httpClient *http.Client
+	request    Request
+	extractor  Extractor
+}
+
+// NewCustomClient returns a new CustomClient. If httpClient is nil,
+// http.DefaultClient is used; an empty method defaults to GET.
+func NewCustomClient(httpClient *http.Client, request Request, extractor Extractor) *CustomClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if request.Method == "" {
+		request.Method = http.MethodGet
+	}
+	return &CustomClient{
+		httpClient: httpClient,
+		request:    request,
+		extractor:  extractor,
+	}
+}
+
+// NewTextClient returns a CustomClient for services that return the address
+// as a plain-text body, such as icanhazip.com or ifconfig.me.
+func NewTextClient(httpClient *http.Client, url string) *CustomClient {
+	return NewCustomClient(httpClient, Request{URL: url}, TextExtractor{})
+}
+
+// GetIP fetches the public IP address.
+func (c *CustomClient) GetIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, c.request.Method, c.request.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range c.request.Headers {
+		req.Header.Set(k, v)
+	}
+	if c.request.Username != "" || c.request.Password != "" {
+		req.SetBasicAuth(c.request.Username, c.request.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := c.extractor.Extract(body)
+	if err != nil {
+		return "", err
+	}
+	ip = strings.TrimSpace(ip)
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("response is not an IP address: %q", truncate(ip, 64))
+	}
+	return ip, nil
+}
+
+// truncate shortens s to at most n bytes for use in error messages.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,215 @@
+package ipify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTextClientGetIP(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		expectedIP  string
+		expectError bool
+	}{
+		{
+			name:       "IPv4 with trailing newline",
+			status:     http.StatusOK,
+			body:       "203.0.113.5\n",
+			expectedIP: "203.0.113.5",
+		},
+		{
+			name:       "IPv6",
+			status:     http.StatusOK,
+			body:       "2001:db8::5",
+			expectedIP: "2001:db8::5",
+		},
+		{
+			name:        "HTML instead of address",
+			status:      http.StatusOK,
+			body:        "<html>login required</html>",
+			expectError: true,
+		},
+		{
+			name:        "non-200",
+			status:      http.StatusBadGateway,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewTextClient(srv.Client(), srv.URL)
+			ip, err := c.GetIP(context.Background())
+
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	for _, name := range []string{SourceIpify, SourceIcanhazip, SourceIfconfigMe} {
+		for _, family := range []Family{IPv4, IPv6} {
+			if _, err := NewSource(name, family); err != nil {
+				t.Errorf("NewSource(%s, %s): unexpected error: %v", name, family, err)
+			}
+		}
+	}
+
+	if _, err := NewSource("whatismyip", IPv4); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
+
+func TestCustomClientRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "secret" {
+			t.Errorf("expected X-Api-Key header, got %q", got)
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "admin" || pass != "hunter2" {
+			t.Errorf("expected basic auth admin/hunter2, got %q/%q", user, pass)
+		}
+		_, _ = w.Write([]byte(`{"wan":{"ipv4":"203.0.113.9"}}`))
+	}))
+	defer srv.Close()
+
+	c := NewCustomClient(srv.Client(), Request{
+		Method:   http.MethodPost,
+		URL:      srv.URL,
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+		Username: "admin",
+		Password: "hunter2",
+	}, JSONExtractor{Path: "wan.ipv4"})
+
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.9" {
+		t.Errorf("expected 203.0.113.9, got %s", ip)
+	}
+}
+
+func TestJSONExtractor(t *testing.T) {
+	body := []byte(`{"ip":"203.0.113.1","data":{"interfaces":[{"address":"10.0.0.1"},{"address":"2001:db8::1"}]},"count":2}`)
+
+	tests := []struct {
+		path        string
+		expected    string
+		expectError bool
+	}{
+		{path: "ip", expected: "203.0.113.1"},
+		{path: "data.interfaces.1.address", expected: "2001:db8::1"},
+		{path: "data.missing", expectError: true},
+		{path: "data.interfaces.5.address", expectError: true},
+		{path: "count", expectError: true},
+		{path: "ip.nested", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := JSONExtractor{Path: tt.path}.Extract(body)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRegexExtractor(t *testing.T) {
+	page := []byte(`<tr><td>LAN IP</td><td>192.168.1.1</td></tr><tr><td>WAN IP</td><td>198.51.100.23</td></tr>`)
+
+	tests := []struct {
+		name        string
+		pattern     string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "capture group",
+			pattern:  `WAN IP</td><td>([0-9.]+)<`,
+			expected: "198.51.100.23",
+		},
+		{
+			name:     "whole match",
+			pattern:  `198\.51\.100\.\d+`,
+			expected: "198.51.100.23",
+		},
+		{
+			name:        "no match",
+			pattern:     `WAN IPv6</td><td>([0-9a-f:]+)<`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewRegexExtractor(tt.pattern)
+			if err != nil {
+				t.Fatalf("failed to compile: %v", err)
+			}
+			got, err := e.Extract(page)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := NewRegexExtractor(`(`); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestCustomClientRejectsNonIP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ip":"unknown"}`))
+	}))
+	defer srv.Close()
+
+	c := NewCustomClient(srv.Client(), Request{URL: srv.URL}, JSONExtractor{Path: "ip"})
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
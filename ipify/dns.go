@@ -0,0 +1,125 @@
+package ipify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/epsilonrhorho/dns-updater/internal/hostport"
+)
+
+// Well-known resolvers that report the address a query was received from.
+var (
+	openDNSResolvers = map[Family][]string{
+		IPv4: {"208.67.222.222:53", "208.67.220.220:53"},
+		IPv6: {"[2620:119:35::35]:53", "[2620:119:53::53]:53"},
+	}
+	googleResolvers = map[Family][]string{
+		IPv4: {"216.239.32.10:53", "216.239.34.10:53"},
+		IPv6: {"[2001:4860:4802:32::a]:53", "[2001:4860:4802:34::a]:53"},
+	}
+)
+
+// DNSClient implements the ClientInterface by querying a resolver that
+// answers with the address the query came from, for networks where HTTP
+// lookups are blocked but DNS is allowed.
+type DNSClient struct {
+	client    *dns.Client
+	family    Family
+	name      string
+	qtype     uint16
+	resolvers []string
+}
+
+// NewOpenDNSClient returns a DNSClient resolving myip.opendns.com against
+// the OpenDNS resolvers, or the given resolvers if any.
+func NewOpenDNSClient(family Family, resolvers ...string) *DNSClient {
+	qtype := dns.TypeA
+	if family == IPv6 {
+		qtype = dns.TypeAAAA
+	}
+	if len(resolvers) == 0 {
+		resolvers = openDNSResolvers[family]
+	}
+	return newDNSClient(family, "myip.opendns.com.", qtype, resolvers)
+}
+
+// NewGoogleDNSClient returns a DNSClient reading the o-o.myaddr.l.google.com
+// TXT record from Google's authoritative servers, or the given resolvers if
+// any.
+func NewGoogleDNSClient(family Family, resolvers ...string) *DNSClient {
+	if len(resolvers) == 0 {
+		resolvers = googleResolvers[family]
+	}
+	return newDNSClient(family, "o-o.myaddr.l.google.com.", dns.TypeTXT, resolvers)
+}
+
+func newDNSClient(family Family, name string, qtype uint16, resolvers []string) *DNSClient {
+	network := "udp4"
+	if family == IPv6 {
+		network = "udp6"
+	}
+	normalized := make([]string, len(resolvers))
+	for i, r := range resolvers {
+		normalized[i] = hostport.WithDefaultPort(r, "53")
+	}
+	return &DNSClient{
+		client:    &dns.Client{Net: network, Timeout: 5 * time.Second},
+		family:    family,
+		name:      name,
+		qtype:     qtype,
+		resolvers: normalized,
+	}
+}
+
+// GetIP queries the resolvers in order and returns the first answer.
+func (c *DNSClient) GetIP(ctx context.Context) (string, error) {
+	var errs []error
+	for _, resolver := range c.resolvers {
+		ip, err := c.query(ctx, resolver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", resolver, err))
+			continue
+		}
+		return ip, nil
+	}
+	return "", fmt.Errorf("DNS lookup of %s failed: %w", c.name, errors.Join(errs...))
+}
+
+// query asks a single resolver for the record.
+func (c *DNSClient) query(ctx context.Context, resolver string) (string, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(c.name, c.qtype)
+
+	resp, _, err := c.client.ExchangeContext(ctx, msg, resolver)
+	if err != nil {
+		return "", err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return "", fmt.Errorf("resolver returned %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		var candidates []string
+		switch r := rr.(type) {
+		case *dns.A:
+			candidates = []string{r.A.String()}
+		case *dns.AAAA:
+			candidates = []string{r.AAAA.String()}
+		case *dns.TXT:
+			candidates = r.Txt
+		}
+		for _, candidate := range candidates {
+			ip := net.ParseIP(strings.TrimSpace(candidate))
+			if ip != nil && (ip.To4() != nil) == (c.family == IPv4) {
+				return ip.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s address in answer", c.family)
+}
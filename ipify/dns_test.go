@@ -0,0 +1,155 @@
+package ipify
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// startDNSServer runs an in-process DNS server answering every query with
+// the address the query was sent from, like OpenDNS and Google do.
+func startDNSServer(t *testing.T, network, addr string, rcode int) string {
+	t.Helper()
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %v", network, addr, err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Rcode = rcode
+		q := r.Question[0]
+		client := w.RemoteAddr().(*net.UDPAddr).IP
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 0}
+
+		if rcode == dns.RcodeSuccess {
+			switch q.Qtype {
+			case dns.TypeA:
+				if q.Name == "myip.opendns.com." && client.To4() != nil {
+					m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: client})
+				}
+			case dns.TypeAAAA:
+				if q.Name == "myip.opendns.com." && client.To4() == nil {
+					m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: client})
+				}
+			case dns.TypeTXT:
+				if q.Name == "o-o.myaddr.l.google.com." {
+					m.Answer = append(m.Answer, &dns.TXT{Hdr: hdr, Txt: []string{client.String()}})
+				}
+			}
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{PacketConn: pc, Handler: handler}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestDNSClientGetIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		network    string
+		listen     string
+		newClient  func(Family, ...string) *DNSClient
+		family     Family
+		expectedIP string
+	}{
+		{
+			name:       "OpenDNS A",
+			network:    "udp4",
+			listen:     "127.0.0.1:0",
+			newClient:  NewOpenDNSClient,
+			family:     IPv4,
+			expectedIP: "127.0.0.1",
+		},
+		{
+			name:       "OpenDNS AAAA",
+			network:    "udp6",
+			listen:     "[::1]:0",
+			newClient:  NewOpenDNSClient,
+			family:     IPv6,
+			expectedIP: "::1",
+		},
+		{
+			name:       "Google TXT over IPv4",
+			network:    "udp4",
+			listen:     "127.0.0.1:0",
+			newClient:  NewGoogleDNSClient,
+			family:     IPv4,
+			expectedIP: "127.0.0.1",
+		},
+		{
+			name:       "Google TXT over IPv6",
+			network:    "udp6",
+			listen:     "[::1]:0",
+			newClient:  NewGoogleDNSClient,
+			family:     IPv6,
+			expectedIP: "::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startDNSServer(t, tt.network, tt.listen, dns.RcodeSuccess)
+
+			c := tt.newClient(tt.family, addr)
+			ip, err := c.GetIP(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestDNSClientFailsOverResolvers(t *testing.T) {
+	refusing := startDNSServer(t, "udp4", "127.0.0.1:0", dns.RcodeRefused)
+	working := startDNSServer(t, "udp4", "127.0.0.1:0", dns.RcodeSuccess)
+
+	c := NewOpenDNSClient(IPv4, refusing, working)
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1, got %s", ip)
+	}
+}
+
+func TestDNSClientNoAnswer(t *testing.T) {
+	refusing := startDNSServer(t, "udp4", "127.0.0.1:0", dns.RcodeRefused)
+
+	c := NewGoogleDNSClient(IPv4, refusing)
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestDNSClientDefaults(t *testing.T) {
+	c := NewOpenDNSClient(IPv6)
+	if c.qtype != dns.TypeAAAA {
+		t.Errorf("expected AAAA query, got %s", dns.TypeToString[c.qtype])
+	}
+	if c.client.Net != "udp6" {
+		t.Errorf("expected udp6, got %s", c.client.Net)
+	}
+	if len(c.resolvers) == 0 {
+		t.Error("expected default resolvers")
+	}
+
+	c = NewGoogleDNSClient(IPv4, "192.0.2.53", "[2001:db8::53]")
+	if c.resolvers[0] != "192.0.2.53:53" || c.resolvers[1] != "[2001:db8::53]:53" {
+		t.Errorf("expected default port to be added, got %v", c.resolvers)
+	}
+}
@@ -0,0 +1,43 @@
+package ipify
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Family selects the address family a lookup should return.
+type Family int
+
+const (
+	IPv4 Family = iota
+	IPv6
+)
+
+// String returns the family name as used in logs.
+func (f Family) String() string {
+	if f == IPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// network returns the TCP network restricted to the family.
+func (f Family) network() string {
+	if f == IPv6 {
+		return "tcp6"
+	}
+	return "tcp4"
+}
+
+// NewHTTPClient returns an http.Client that only connects over the given
+// family, so dual-stack services report the address of that family.
+func NewHTTPClient(family Family) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, family.network(), addr)
+	}
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
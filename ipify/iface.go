@@ -0,0 +1,136 @@
+package ipify
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IPv6 address flags from linux/if_addr.h as reported in /proc/net/if_inet6.
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDeprecated = 0x20
+)
+
+// cgnatNet is the shared address space used for carrier-grade NAT (RFC 6598).
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// InterfaceClient implements the ClientInterface by reading the public
+// address assigned directly to a local network interface.
+type InterfaceClient struct {
+	name   string
+	family Family
+
+	// interfaceAddrs lists the addresses of the named interface.
+	interfaceAddrs func(name string) ([]net.Addr, error)
+	// ifInet6Path is the procfs file listing IPv6 address flags.
+	ifInet6Path string
+}
+
+// NewInterfaceClient returns a new InterfaceClient reading addresses of the
+// given family from the interface called name, e.g. "eth0" or "ppp0".
+func NewInterfaceClient(name string, family Family) *InterfaceClient {
+	return &InterfaceClient{
+		name:           name,
+		family:         family,
+		interfaceAddrs: interfaceAddrs,
+		ifInet6Path:    "/proc/net/if_inet6",
+	}
+}
+
+func interfaceAddrs(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
+
+// GetIP returns the first public address of the configured family. Private,
+// loopback, link-local, CGNAT and unique local addresses are skipped, as are
+// temporary and deprecated IPv6 addresses.
+func (c *InterfaceClient) GetIP(ctx context.Context) (string, error) {
+	addrs, err := c.interfaceAddrs(c.name)
+	if err != nil {
+		return "", fmt.Errorf("interface %s: %w", c.name, err)
+	}
+
+	var flags map[string]int
+	if c.family == IPv6 {
+		flags, err = readIPv6Flags(c.ifInet6Path, c.name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, addr := range addrs {
+		ip := addrIP(addr)
+		if ip == nil || (ip.To4() != nil) != (c.family == IPv4) {
+			continue
+		}
+		if !isPublic(ip) {
+			continue
+		}
+		if flags[ip.String()]&(ifaFlagTemporary|ifaFlagDeprecated) != 0 {
+			continue
+		}
+		return ip.String(), nil
+	}
+
+	return "", fmt.Errorf("interface %s has no public %s address", c.name, c.family)
+}
+
+// addrIP extracts the IP from an interface address.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPNet:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// isPublic reports whether ip is a globally routable unicast address.
+func isPublic(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !cgnatNet.Contains(ip)
+}
+
+// readIPv6Flags returns the address flags of the IPv6 addresses assigned to
+// the interface, keyed by address. A missing file yields no flags so that
+// the client still works on systems without procfs.
+func readIPv6Flags(path, name string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	flags := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address ifindex prefixlen scope flags name
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || fields[5] != name {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		flag, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil {
+			continue
+		}
+		flags[net.IP(raw).String()] = int(flag)
+	}
+	return flags, scanner.Err()
+}
@@ -0,0 +1,132 @@
+package ipify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, s string) net.Addr {
+	t.Helper()
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("invalid CIDR %s: %v", s, err)
+	}
+	ipNet.IP = ip
+	return ipNet
+}
+
+func TestInterfaceClientGetIP(t *testing.T) {
+	ifInet6 := "" +
+		"20010db8000000000000000000000bad 02 40 00 01 eth0\n" +
+		"20010db8000000000000000000000dec 02 40 00 20 eth0\n" +
+		"20010db80000000000000000000000aa 02 40 00 80 eth0\n" +
+		"20010db8000000000000000000000001 03 40 00 01 eth1\n"
+
+	tests := []struct {
+		name        string
+		family      Family
+		addrs       []string
+		expectedIP  string
+		expectError bool
+	}{
+		{
+			name:       "skips private and CGNAT IPv4",
+			family:     IPv4,
+			addrs:      []string{"127.0.0.1/8", "192.168.1.10/24", "100.64.3.4/10", "203.0.113.9/24"},
+			expectedIP: "203.0.113.9",
+		},
+		{
+			name:       "skips link-local, ULA, temporary and deprecated IPv6",
+			family:     IPv6,
+			addrs:      []string{"fe80::1/64", "fd00::1/64", "2001:db8::bad/64", "2001:db8::dec/64", "2001:db8::aa/64"},
+			expectedIP: "2001:db8::aa",
+		},
+		{
+			name:       "IPv6 lookup ignores IPv4 addresses",
+			family:     IPv6,
+			addrs:      []string{"203.0.113.9/24", "2001:db8::aa/64"},
+			expectedIP: "2001:db8::aa",
+		},
+		{
+			name:        "only private addresses",
+			family:      IPv4,
+			addrs:       []string{"10.0.0.2/8", "169.254.1.1/16"},
+			expectError: true,
+		},
+		{
+			name:        "only temporary IPv6 address",
+			family:      IPv6,
+			addrs:       []string{"2001:db8::bad/64"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "if_inet6")
+			if err := os.WriteFile(path, []byte(ifInet6), 0600); err != nil {
+				t.Fatalf("failed to write if_inet6: %v", err)
+			}
+
+			var addrs []net.Addr
+			for _, a := range tt.addrs {
+				addrs = append(addrs, mustCIDR(t, a))
+			}
+
+			c := NewInterfaceClient("eth0", tt.family)
+			c.ifInet6Path = path
+			c.interfaceAddrs = func(name string) ([]net.Addr, error) {
+				if name != "eth0" {
+					t.Errorf("unexpected interface %s", name)
+				}
+				return addrs, nil
+			}
+
+			ip, err := c.GetIP(context.Background())
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expected error, got %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestInterfaceClientMissingInterface(t *testing.T) {
+	errNoIface := errors.New("no such network interface")
+	c := NewInterfaceClient("ppp0", IPv4)
+	c.interfaceAddrs = func(name string) ([]net.Addr, error) {
+		return nil, errNoIface
+	}
+
+	if _, err := c.GetIP(context.Background()); !errors.Is(err, errNoIface) {
+		t.Fatalf("expected interface error, got %v", err)
+	}
+}
+
+func TestInterfaceClientWithoutProcfs(t *testing.T) {
+	c := NewInterfaceClient("eth0", IPv6)
+	c.ifInet6Path = filepath.Join(t.TempDir(), "missing")
+	c.interfaceAddrs = func(name string) ([]net.Addr, error) {
+		return []net.Addr{mustCIDR(t, "2001:db8::aa/64")}, nil
+	}
+
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "2001:db8::aa" {
+		t.Errorf("expected 2001:db8::aa, got %s", ip)
+	}
+}
@@ -18,7 +18,8 @@ type Client struct {
 	baseURL    string
 }
 
-// NewClient returns a new Client. If httpClient is nil, http.DefaultClient is used.
+// NewClient returns a new Client that looks up the public IPv4 address.
+// If httpClient is nil, http.DefaultClient is used.
 func NewClient(httpClient *http.Client) *Client {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
@@ -29,6 +30,31 @@ func NewClient(httpClient *http.Client) *Client {
 	}
 }
 
+// NewIPv6Client returns a new Client that looks up the public IPv6 address
+// using the IPv6-only ipify endpoint. If httpClient is nil, http.DefaultClient
+// is used.
+func NewIPv6Client(httpClient *http.Client) *Client {
+	c := NewClient(httpClient)
+	c.baseURL = "https://api6.ipify.org?format=json"
+	return c
+}
+
+// StatusError is returned when a lookup service answers with a status
+// other than 200 OK.
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s", e.Status)
+}
+
+// HTTPStatusCode returns the HTTP status code of the response.
+func (e *StatusError) HTTPStatusCode() int {
+	return e.Code
+}
+
 // ipifyResponse represents the JSON structure returned by ipify.io.
 type ipifyResponse struct {
 	IP string `json:"ip"`
@@ -48,7 +74,7 @@ func (c *Client) GetIP(ctx context.Context) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+		return "", &StatusError{Code: resp.StatusCode, Status: resp.Status}
 	}
 
 	var body ipifyResponse
@@ -39,8 +39,12 @@ func TestClientGetIPNon200(t *testing.T) {
 	c.baseURL = srv.URL
 
 	_, err := c.GetIP(context.Background())
-	if err == nil {
-		t.Fatal("expected error, got nil")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	if statusErr.HTTPStatusCode() != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", statusErr.HTTPStatusCode())
 	}
 }
 
@@ -63,3 +67,13 @@ func TestClientGetIPInvalidJSON(t *testing.T) {
 		t.Fatalf("expected json syntax error, got %T", err)
 	}
 }
+
+func TestNewIPv6Client(t *testing.T) {
+	c := NewIPv6Client(nil)
+	if c.httpClient != http.DefaultClient {
+		t.Error("expected default HTTP client")
+	}
+	if c.baseURL != "https://api6.ipify.org?format=json" {
+		t.Errorf("unexpected base URL %q", c.baseURL)
+	}
+}
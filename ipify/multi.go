@@ -0,0 +1,229 @@
+package ipify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategy decides how answers from multiple sources are combined.
+type Strategy string
+
+const (
+	// StrategyFirst queries the sources in order and returns the first
+	// successful answer.
+	StrategyFirst Strategy = "first"
+	// StrategyMajority queries all sources and returns the address reported
+	// by more than half of the configured sources. Sources that fail count
+	// against every address, so a lone answer cannot win by default.
+	StrategyMajority Strategy = "majority"
+	// StrategyAll queries all sources and requires every one of them to
+	// answer with the same address.
+	StrategyAll Strategy = "all"
+)
+
+// ParseStrategy converts a configuration value into a Strategy. An empty
+// value selects StrategyFirst.
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(strings.ToLower(s)) {
+	case "", StrategyFirst:
+		return StrategyFirst, nil
+	case StrategyMajority:
+		return StrategyMajority, nil
+	case StrategyAll:
+		return StrategyAll, nil
+	default:
+		return "", fmt.Errorf("unknown IP source strategy: %s", s)
+	}
+}
+
+// Source is a named ClientInterface queried by a MultiClient.
+type Source struct {
+	Name   string
+	Client ClientInterface
+}
+
+// Result holds the outcome of querying every source of a MultiClient.
+type Result struct {
+	IP         string            // the agreed address, empty if none
+	Answers    map[string]string // source name -> address
+	Errors     map[string]error  // source name -> lookup error
+	Dissenters []string          // sources whose answer differs from IP
+}
+
+// DisagreementError is returned when the sources do not reach the agreement
+// the strategy requires.
+type DisagreementError struct {
+	Strategy Strategy
+	Result   Result
+}
+
+func (e *DisagreementError) Error() string {
+	var parts []string
+	for _, name := range sortedKeys(e.Result.Answers) {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, e.Result.Answers[name]))
+	}
+	for _, name := range sortedKeys(e.Result.Errors) {
+		parts = append(parts, fmt.Sprintf("%s failed: %v", name, e.Result.Errors[name]))
+	}
+	return fmt.Sprintf("IP sources did not agree (%s): %s", e.Strategy, strings.Join(parts, ", "))
+}
+
+// MultiClient implements the ClientInterface by querying several sources
+// and combining their answers according to a Strategy.
+type MultiClient struct {
+	sources  []Source
+	strategy Strategy
+	timeout  time.Duration
+}
+
+// NewMultiClient returns a new MultiClient. A zero timeout leaves the
+// per-source lookup bounded only by the caller's context.
+func NewMultiClient(strategy Strategy, timeout time.Duration, sources ...Source) *MultiClient {
+	return &MultiClient{
+		sources:  sources,
+		strategy: strategy,
+		timeout:  timeout,
+	}
+}
+
+// GetIP fetches the public IP address from the configured sources.
+func (c *MultiClient) GetIP(ctx context.Context) (string, error) {
+	result, err := c.Lookup(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range result.Dissenters {
+		log.Printf("IP source %s disagreed: reported %s, using %s", name, result.Answers[name], result.IP)
+	}
+	return result.IP, nil
+}
+
+// Lookup queries the sources and returns the detailed result.
+func (c *MultiClient) Lookup(ctx context.Context) (Result, error) {
+	if len(c.sources) == 0 {
+		return Result{}, errors.New("no IP sources configured")
+	}
+	if c.strategy == StrategyFirst {
+		return c.lookupFirst(ctx)
+	}
+
+	result := c.queryAll(ctx)
+	counts := make(map[string]int)
+	for _, ip := range result.Answers {
+		counts[ip]++
+	}
+
+	switch c.strategy {
+	case StrategyAll:
+		if len(result.Errors) > 0 || len(counts) != 1 {
+			return result, &DisagreementError{Strategy: c.strategy, Result: result}
+		}
+		for ip := range counts {
+			result.IP = ip
+		}
+	case StrategyMajority:
+		for ip, n := range counts {
+			if 2*n > len(c.sources) {
+				result.IP = ip
+			}
+		}
+		if result.IP == "" {
+			return result, &DisagreementError{Strategy: c.strategy, Result: result}
+		}
+	default:
+		return result, fmt.Errorf("unknown IP source strategy: %s", c.strategy)
+	}
+
+	for _, name := range sortedKeys(result.Answers) {
+		if result.Answers[name] != result.IP {
+			result.Dissenters = append(result.Dissenters, name)
+		}
+	}
+	return result, nil
+}
+
+// lookupFirst returns the answer of the first source that succeeds.
+func (c *MultiClient) lookupFirst(ctx context.Context) (Result, error) {
+	result := Result{
+		Answers: make(map[string]string),
+		Errors:  make(map[string]error),
+	}
+	var errs []error
+	for _, src := range c.sources {
+		ip, err := c.query(ctx, src)
+		if err != nil {
+			result.Errors[src.Name] = err
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+			continue
+		}
+		result.Answers[src.Name] = ip
+		result.IP = ip
+		return result, nil
+	}
+	return result, fmt.Errorf("all IP sources failed: %w", errors.Join(errs...))
+}
+
+// queryAll queries every source concurrently.
+func (c *MultiClient) queryAll(ctx context.Context) Result {
+	result := Result{
+		Answers: make(map[string]string),
+		Errors:  make(map[string]error),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, src := range c.sources {
+		wg.Add(1)
+		go func(src Source) {
+			defer wg.Done()
+			ip, err := c.query(ctx, src)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors[src.Name] = err
+				return
+			}
+			result.Answers[src.Name] = ip
+		}(src)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// query asks a single source and normalizes its answer so that different
+// spellings of the same address compare equal.
+func (c *MultiClient) query(ctx context.Context, src Source) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	ip, err := src.Client.GetIP(ctx)
+	if err != nil {
+		return "", err
+	}
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address: %q", ip)
+	}
+	return parsed.String(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
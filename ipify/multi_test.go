@@ -0,0 +1,236 @@
+package ipify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubClient struct {
+	ip    string
+	err   error
+	calls int
+}
+
+func (s *stubClient) GetIP(ctx context.Context) (string, error) {
+	s.calls++
+	return s.ip, s.err
+}
+
+var errLookup = errors.New("lookup failed")
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Strategy
+		expectError bool
+	}{
+		{input: "", expected: StrategyFirst},
+		{input: "first", expected: StrategyFirst},
+		{input: "Majority", expected: StrategyMajority},
+		{input: "all", expected: StrategyAll},
+		{input: "quorum", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			strategy, err := ParseStrategy(tt.input)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strategy != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, strategy)
+			}
+		})
+	}
+}
+
+func TestMultiClientFirstFailsOver(t *testing.T) {
+	failing := &stubClient{err: errLookup}
+	working := &stubClient{ip: "203.0.113.7"}
+	unused := &stubClient{ip: "203.0.113.8"}
+
+	c := NewMultiClient(StrategyFirst, time.Second,
+		Source{Name: "a", Client: failing},
+		Source{Name: "b", Client: working},
+		Source{Name: "c", Client: unused},
+	)
+
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.7" {
+		t.Errorf("expected 203.0.113.7, got %s", ip)
+	}
+	if unused.calls != 0 {
+		t.Error("sources after the first success should not be queried")
+	}
+}
+
+func TestMultiClientFirstAllFail(t *testing.T) {
+	c := NewMultiClient(StrategyFirst, 0,
+		Source{Name: "a", Client: &stubClient{err: errLookup}},
+		Source{Name: "b", Client: &stubClient{err: errLookup}},
+	)
+
+	_, err := c.GetIP(context.Background())
+	if !errors.Is(err, errLookup) {
+		t.Fatalf("expected lookup error, got %v", err)
+	}
+}
+
+func TestMultiClientMajority(t *testing.T) {
+	tests := []struct {
+		name        string
+		answers     []*stubClient
+		expectedIP  string
+		dissenters  []string
+		expectError bool
+	}{
+		{
+			name: "two of three agree",
+			answers: []*stubClient{
+				{ip: "203.0.113.1"},
+				{ip: "203.0.113.1"},
+				{ip: "198.51.100.1"},
+			},
+			expectedIP: "203.0.113.1",
+			dissenters: []string{"s2"},
+		},
+		{
+			name: "normalizes IPv6 spelling",
+			answers: []*stubClient{
+				{ip: "2001:DB8::1"},
+				{ip: "2001:db8:0::1\n"},
+			},
+			expectedIP: "2001:db8::1",
+		},
+		{
+			name: "failed source is ignored",
+			answers: []*stubClient{
+				{ip: "203.0.113.1"},
+				{err: errLookup},
+				{ip: "203.0.113.1"},
+			},
+			expectedIP: "203.0.113.1",
+		},
+		{
+			name: "lone answer when two of three fail",
+			answers: []*stubClient{
+				{err: errLookup},
+				{ip: "203.0.113.1"},
+				{err: errLookup},
+			},
+			expectError: true,
+		},
+		{
+			name: "agreeing answers outnumbered by failures",
+			answers: []*stubClient{
+				{ip: "203.0.113.1"},
+				{ip: "203.0.113.1"},
+				{err: errLookup},
+				{err: errLookup},
+			},
+			expectError: true,
+		},
+		{
+			name: "tie is rejected",
+			answers: []*stubClient{
+				{ip: "203.0.113.1"},
+				{ip: "198.51.100.1"},
+			},
+			expectError: true,
+		},
+		{
+			name: "all sources failed",
+			answers: []*stubClient{
+				{err: errLookup},
+				{err: errLookup},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []Source
+			for i, a := range tt.answers {
+				sources = append(sources, Source{Name: "s" + string(rune('0'+i)), Client: a})
+			}
+			c := NewMultiClient(StrategyMajority, time.Second, sources...)
+
+			result, err := c.Lookup(context.Background())
+			if tt.expectError {
+				var disagreement *DisagreementError
+				if !errors.As(err, &disagreement) {
+					t.Fatalf("expected disagreement error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.IP != tt.expectedIP {
+				t.Errorf("expected %s, got %s", tt.expectedIP, result.IP)
+			}
+			if len(result.Dissenters) != len(tt.dissenters) {
+				t.Fatalf("expected dissenters %v, got %v", tt.dissenters, result.Dissenters)
+			}
+			for i := range tt.dissenters {
+				if result.Dissenters[i] != tt.dissenters[i] {
+					t.Errorf("expected dissenters %v, got %v", tt.dissenters, result.Dissenters)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiClientAll(t *testing.T) {
+	agree := NewMultiClient(StrategyAll, time.Second,
+		Source{Name: "a", Client: &stubClient{ip: "203.0.113.1"}},
+		Source{Name: "b", Client: &stubClient{ip: "203.0.113.1"}},
+	)
+	ip, err := agree.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.1" {
+		t.Errorf("expected 203.0.113.1, got %s", ip)
+	}
+
+	disagree := NewMultiClient(StrategyAll, time.Second,
+		Source{Name: "a", Client: &stubClient{ip: "203.0.113.1"}},
+		Source{Name: "b", Client: &stubClient{ip: "198.51.100.1"}},
+	)
+	_, err = disagree.GetIP(context.Background())
+	var disagreement *DisagreementError
+	if !errors.As(err, &disagreement) {
+		t.Fatalf("expected disagreement error, got %v", err)
+	}
+	if disagreement.Result.Answers["b"] != "198.51.100.1" {
+		t.Errorf("expected answers to be reported, got %v", disagreement.Result.Answers)
+	}
+
+	failing := NewMultiClient(StrategyAll, time.Second,
+		Source{Name: "a", Client: &stubClient{ip: "203.0.113.1"}},
+		Source{Name: "b", Client: &stubClient{err: errLookup}},
+	)
+	if _, err := failing.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error when a source fails")
+	}
+}
+
+func TestMultiClientNoSources(t *testing.T) {
+	c := NewMultiClient(StrategyMajority, 0)
+	if _, err := c.GetIP(context.Background()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,35 @@
+package ipify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the built-in IP discovery services.
+const (
+	SourceIpify      = "ipify"
+	SourceIcanhazip  = "icanhazip"
+	SourceIfconfigMe = "ifconfig.me"
+)
+
+// NewSource returns a client for the named built-in discovery service that
+// reports the address of the given family.
+func NewSource(name string, family Family) (ClientInterface, error) {
+	switch strings.ToLower(name) {
+	case SourceIpify:
+		if family == IPv6 {
+			return NewIPv6Client(nil), nil
+		}
+		return NewClient(nil), nil
+	case SourceIcanhazip:
+		if family == IPv6 {
+			return NewTextClient(nil, "https://ipv6.icanhazip.com"), nil
+		}
+		return NewTextClient(nil, "https://ipv4.icanhazip.com"), nil
+	case SourceIfconfigMe:
+		// ifconfig.me is dual-stack, so pin the connection to the family.
+		return NewTextClient(NewHTTPClient(family), "https://ifconfig.me/ip"), nil
+	default:
+		return nil, fmt.Errorf("unknown IP source: %s", name)
+	}
+}
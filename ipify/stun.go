@@ -0,0 +1,229 @@
+package ipify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/internal/hostport"
+)
+
+// STUN message constants from RFC 5389.
+const (
+	stunBindingRequest       = 0x0001
+	stunBindingSuccess       = 0x0101
+	stunMagicCookie          = 0x2112A442
+	stunHeaderSize           = 20
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXORMappedAddress = 0x0020
+	stunFamilyIPv4           = 0x01
+	stunFamilyIPv6           = 0x02
+
+	// stunInitialRTO is the initial retransmission timeout from RFC 5389.
+	stunInitialRTO = 500 * time.Millisecond
+)
+
+// defaultSTUNServers are public STUN servers reachable over IPv4 and IPv6.
+var defaultSTUNServers = []string{
+	"stun.l.google.com:19302",
+	"stun.cloudflare.com:3478",
+}
+
+// STUNClient implements the ClientInterface by sending STUN Binding
+// requests over UDP and reading the XOR-MAPPED-ADDRESS of the response.
+type STUNClient struct {
+	family  Family
+	servers []string
+	timeout time.Duration
+}
+
+// NewSTUNClient returns a new STUNClient querying the given servers in
+// order, or a list of public servers if none are given. A server without a
+// port uses the default STUN port 3478.
+func NewSTUNClient(family Family, servers ...string) *STUNClient {
+	if len(servers) == 0 {
+		servers = defaultSTUNServers
+	}
+	normalized := make([]string, len(servers))
+	for i, s := range servers {
+		normalized[i] = hostport.WithDefaultPort(s, "3478")
+	}
+	return &STUNClient{
+		family:  family,
+		servers: normalized,
+		timeout: 3 * time.Second,
+	}
+}
+
+// GetIP returns the mapped address reported by the first server to answer.
+func (c *STUNClient) GetIP(ctx context.Context) (string, error) {
+	var errs []error
+	for _, server := range c.servers {
+		ip, err := c.bind(ctx, server)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			continue
+		}
+		return ip.String(), nil
+	}
+	return "", fmt.Errorf("STUN lookup failed: %w", errors.Join(errs...))
+}
+
+// bind performs a single Binding transaction with server.
+func (c *STUNClient) bind(ctx context.Context, server string) (net.IP, error) {
+	network := "udp4"
+	if c.family == IPv6 {
+		network = "udp6"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	if _, err := rand.Read(req[8:20]); err != nil {
+		return nil, err
+	}
+	txID := req[8:20]
+
+	deadline, _ := ctx.Deadline()
+	buf := make([]byte, 1500)
+	for rto := stunInitialRTO; ; rto *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		wait := time.Now().Add(rto)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		if err := conn.SetReadDeadline(wait); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			ip, err := parseBindingResponse(buf[:n], txID)
+			if errors.Is(err, errNotOurResponse) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if (ip.To4() != nil) != (c.family == IPv4) {
+				return nil, fmt.Errorf("server mapped us to %s, expected %s", ip, c.family)
+			}
+			return ip, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, errors.New("no response")
+		}
+	}
+}
+
+// errNotOurResponse marks datagrams that do not belong to the transaction.
+var errNotOurResponse = errors.New("not a response to our request")
+
+// parseBindingResponse extracts the mapped address from a Binding success
+// response, preferring XOR-MAPPED-ADDRESS over the legacy MAPPED-ADDRESS.
+func parseBindingResponse(msg, txID []byte) (net.IP, error) {
+	if len(msg) < stunHeaderSize ||
+		binary.BigEndian.Uint32(msg[4:8]) != stunMagicCookie ||
+		string(msg[8:20]) != string(txID) {
+		return nil, errNotOurResponse
+	}
+	if msgType := binary.BigEndian.Uint16(msg[0:2]); msgType != stunBindingSuccess {
+		return nil, fmt.Errorf("unexpected STUN message type 0x%04x", msgType)
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[2:4]))
+	if stunHeaderSize+length > len(msg) {
+		return nil, errors.New("truncated STUN message")
+	}
+	attrs := msg[stunHeaderSize : stunHeaderSize+length]
+
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrLen > len(attrs) {
+			return nil, errors.New("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXORMappedAddress:
+			ip, err := parseAddress(value, msg[4:20])
+			if err != nil {
+				return nil, err
+			}
+			return ip, nil
+		case stunAttrMappedAddress:
+			if ip, err := parseAddress(value, nil); err == nil {
+				mapped = ip
+			}
+		}
+
+		// Attributes are padded to a multiple of four bytes.
+		padded := (attrLen + 3) &^ 3
+		if 4+padded > len(attrs) {
+			break
+		}
+		attrs = attrs[4+padded:]
+	}
+
+	if mapped != nil {
+		return mapped, nil
+	}
+	return nil, errors.New("response has no mapped address")
+}
+
+// parseAddress decodes a (XOR-)MAPPED-ADDRESS value. If xorKey is non-nil
+// it holds the magic cookie followed by the transaction ID.
+func parseAddress(value, xorKey []byte) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("malformed address attribute")
+	}
+
+	var size int
+	switch value[1] {
+	case stunFamilyIPv4:
+		size = net.IPv4len
+	case stunFamilyIPv6:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown address family 0x%02x", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("malformed address attribute")
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip, nil
+}
@@ -0,0 +1,175 @@
+package ipify
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// startSTUNServer runs an in-process STUN server answering Binding requests
+// with the sender's address. If xor is false the legacy MAPPED-ADDRESS
+// attribute is used instead of XOR-MAPPED-ADDRESS.
+func startSTUNServer(t *testing.T, network, addr string, xor bool) string {
+	t.Helper()
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s %s: %v", network, addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, raddr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize || binary.BigEndian.Uint16(buf[0:2]) != stunBindingRequest {
+				continue
+			}
+			req := buf[:n]
+			udpAddr := raddr.(*net.UDPAddr)
+
+			family, ip := byte(stunFamilyIPv4), []byte(udpAddr.IP.To4())
+			if ip == nil {
+				family, ip = stunFamilyIPv6, []byte(udpAddr.IP.To16())
+			}
+			port := uint16(udpAddr.Port)
+
+			attrType := uint16(stunAttrMappedAddress)
+			if xor {
+				attrType = stunAttrXORMappedAddress
+				port ^= uint16(stunMagicCookie >> 16)
+				key := req[4:20]
+				xored := make([]byte, len(ip))
+				for i := range ip {
+					xored[i] = ip[i] ^ key[i]
+				}
+				ip = xored
+			}
+
+			value := make([]byte, 4+len(ip))
+			value[1] = family
+			binary.BigEndian.PutUint16(value[2:4], port)
+			copy(value[4:], ip)
+
+			// An unrelated attribute first, to exercise attribute skipping.
+			software := []byte("fake")
+			attrs := make([]byte, 0, 64)
+			attrs = binary.BigEndian.AppendUint16(attrs, 0x8022)
+			attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(software)))
+			attrs = append(attrs, software...)
+			attrs = binary.BigEndian.AppendUint16(attrs, attrType)
+			attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(value)))
+			attrs = append(attrs, value...)
+
+			resp := make([]byte, stunHeaderSize, stunHeaderSize+len(attrs))
+			binary.BigEndian.PutUint16(resp[0:2], stunBindingSuccess)
+			binary.BigEndian.PutUint16(resp[2:4], uint16(len(attrs)))
+			copy(resp[4:20], req[4:20])
+			resp = append(resp, attrs...)
+
+			_, _ = conn.WriteTo(resp, raddr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestSTUNClientGetIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		network    string
+		listen     string
+		family     Family
+		xor        bool
+		expectedIP string
+	}{
+		{
+			name:       "IPv4 XOR-MAPPED-ADDRESS",
+			network:    "udp4",
+			listen:     "127.0.0.1:0",
+			family:     IPv4,
+			xor:        true,
+			expectedIP: "127.0.0.1",
+		},
+		{
+			name:       "IPv6 XOR-MAPPED-ADDRESS",
+			network:    "udp6",
+			listen:     "[::1]:0",
+			family:     IPv6,
+			xor:        true,
+			expectedIP: "::1",
+		},
+		{
+			name:       "legacy MAPPED-ADDRESS",
+			network:    "udp4",
+			listen:     "127.0.0.1:0",
+			family:     IPv4,
+			expectedIP: "127.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startSTUNServer(t, tt.network, tt.listen, tt.xor)
+
+			c := NewSTUNClient(tt.family, server)
+			ip, err := c.GetIP(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestSTUNClientFailsOverServers(t *testing.T) {
+	silent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer silent.Close()
+	working := startSTUNServer(t, "udp4", "127.0.0.1:0", true)
+
+	c := NewSTUNClient(IPv4, silent.LocalAddr().String(), working)
+	c.timeout = 200 * time.Millisecond
+
+	ip, err := c.GetIP(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1, got %s", ip)
+	}
+}
+
+func TestParseBindingResponseRejectsOtherTransactions(t *testing.T) {
+	msg := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingSuccess)
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], "other-txn-id")
+
+	if _, err := parseBindingResponse(msg, []byte("our-txn-id!!")); err != errNotOurResponse {
+		t.Fatalf("expected errNotOurResponse, got %v", err)
+	}
+	if _, err := parseBindingResponse(msg, []byte("other-txn-id")); err == nil {
+		t.Fatal("expected error for response without mapped address")
+	}
+}
+
+func TestNewSTUNClientDefaults(t *testing.T) {
+	c := NewSTUNClient(IPv4)
+	if len(c.servers) != len(defaultSTUNServers) {
+		t.Errorf("expected default servers, got %v", c.servers)
+	}
+
+	c = NewSTUNClient(IPv6, "stun.example.com")
+	if c.servers[0] != "stun.example.com:3478" {
+		t.Errorf("expected default port, got %s", c.servers[0])
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 	"os/signal"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 
@@ -17,39 +16,162 @@ import (
 
 	"github.com/epsilonrhorho/dns-updater/dns"
 	"github.com/epsilonrhorho/dns-updater/ipify"
+	"github.com/epsilonrhorho/dns-updater/netwatch"
+	"github.com/epsilonrhorho/dns-updater/propagation"
 	"github.com/epsilonrhorho/dns-updater/service"
 	"github.com/epsilonrhorho/dns-updater/storage"
 )
 
 type RecordConfig struct {
-	Provider         string        `yaml:"provider"`
-	TTL              time.Duration `yaml:"ttl,omitempty"`
-	AWSAccessKeyID   string        `yaml:"aws_access_key_id,omitempty"`
-	AWSSecretKey     string        `yaml:"aws_secret_key,omitempty"`
-	AWSRegion        string        `yaml:"aws_region,omitempty"`
-	CFAPIToken       string        `yaml:"cf_api_token,omitempty"`
-	CFEmail          string        `yaml:"cf_email,omitempty"`
-	CFAPIKey         string        `yaml:"cf_api_key,omitempty"`
+	Provider                string           `yaml:"provider"`
+	Zone                    string           `yaml:"zone,omitempty"`
+	TTL                     time.Duration    `yaml:"ttl,omitempty"`
+	Types                   []string         `yaml:"types,omitempty"`
+	IPSources               *IPSourcesConfig `yaml:"ip_sources,omitempty"`
+	AllowCIDRs              []string         `yaml:"allow_cidrs,omitempty"`
+	DenyCIDRs               []string         `yaml:"deny_cidrs,omitempty"`
+	AWSAccessKeyID          string           `yaml:"aws_access_key_id,omitempty"`
+	AWSSecretKey            string           `yaml:"aws_secret_key,omitempty"`
+	AWSRegion               string           `yaml:"aws_region,omitempty"`
+	AWSHostedZoneID         string           `yaml:"hosted_zone_id,omitempty"`
+	AWSSetIdentifier        string           `yaml:"aws_set_identifier,omitempty"`
+	AWSProfile              string           `yaml:"aws_profile,omitempty"`
+	AWSRoleARN              string           `yaml:"aws_role_arn,omitempty"`
+	AWSExternalID           string           `yaml:"aws_external_id,omitempty"`
+	AWSRoleSessionName      string           `yaml:"aws_role_session_name,omitempty"`
+	AWSWebIdentityTokenFile string           `yaml:"aws_web_identity_token_file,omitempty"`
+	CFAPIToken              string           `yaml:"cf_api_token,omitempty"`
+	CFEmail                 string           `yaml:"cf_email,omitempty"`
+	CFAPIKey                string           `yaml:"cf_api_key,omitempty"`
+	CFProxied               *bool            `yaml:"cf_proxied,omitempty"`
+	CFComment               *string          `yaml:"cf_comment,omitempty"`
+	CFTags                  []string         `yaml:"cf_tags,omitempty"`
+	RFC2136Server           string           `yaml:"rfc2136_server,omitempty"`
+	RFC2136KeyName          string           `yaml:"rfc2136_key_name,omitempty"`
+	RFC2136Secret           string           `yaml:"rfc2136_secret,omitempty"`
+	RFC2136Algorithm        string           `yaml:"rfc2136_algorithm,omitempty"`
+	RFC2136Prerequisite     string           `yaml:"rfc2136_prerequisite,omitempty"`
+	PDNSAPIURL              string           `yaml:"pdns_api_url,omitempty"`
+	PDNSServerID            string           `yaml:"pdns_server_id,omitempty"`
+	PDNSAPIKey              string           `yaml:"pdns_api_key,omitempty"`
+	DOAPIToken              string           `yaml:"do_api_token,omitempty"`
+	HetznerAPIToken         string           `yaml:"hetzner_api_token,omitempty"`
+	LinodeAPIToken          string           `yaml:"linode_api_token,omitempty"`
+	DynDNSServer            string           `yaml:"dyndns_server,omitempty"`
+	DynDNSUsername          string           `yaml:"dyndns_username,omitempty"`
+	DynDNSPassword          string           `yaml:"dyndns_password,omitempty"`
+	DuckDNSToken            string           `yaml:"duckdns_token,omitempty"`
+	Dynv6Token              string           `yaml:"dynv6_token,omitempty"`
+	DeSECToken              string           `yaml:"desec_token,omitempty"`
+	GCPProject              string           `yaml:"gcp_project,omitempty"`
+	GCPCredentialsFile      string           `yaml:"gcp_credentials_file,omitempty"`
+	AzureSubscriptionID     string           `yaml:"azure_subscription_id,omitempty"`
+	AzureResourceGroup      string           `yaml:"azure_resource_group,omitempty"`
+	AzureTenantID           string           `yaml:"azure_tenant_id,omitempty"`
+	AzureClientID           string           `yaml:"azure_client_id,omitempty"`
+	AzureClientSecret       string           `yaml:"azure_client_secret,omitempty"`
+}
+
+// WatchConfig configures event-driven updates from netlink notifications.
+type WatchConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Debounce   time.Duration `yaml:"debounce,omitempty"`
+	Interfaces []string      `yaml:"interfaces,omitempty"`
+}
+
+// RetryConfig configures retries of failed lookups and updates within an
+// update cycle.
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts,omitempty"`
+	InitialBackoff time.Duration `yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `yaml:"max_backoff,omitempty"`
+	Jitter         *float64      `yaml:"jitter,omitempty"`
+}
+
+// VerifyConfig configures the check that updates are served by the
+// authoritative name servers.
+type VerifyConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+	Interval  time.Duration `yaml:"interval,omitempty"`
+	Resolvers []string      `yaml:"resolvers,omitempty"`
 }
 
 type Config struct {
-	UpdateInterval time.Duration                `yaml:"update_interval"`
-	StoragePath    string                       `yaml:"storage_path"`
-	Records        map[string]RecordConfig      `yaml:"records"`
+	UpdateInterval    time.Duration           `yaml:"update_interval"`
+	ReconcileInterval time.Duration           `yaml:"reconcile_interval,omitempty"`
+	StoragePath       string                  `yaml:"storage_path"`
+	IPSources         IPSourcesConfig         `yaml:"ip_sources,omitempty"`
+	Watch             WatchConfig             `yaml:"watch,omitempty"`
+	Retry             RetryConfig             `yaml:"retry,omitempty"`
+	Verify            VerifyConfig            `yaml:"verify,omitempty"`
+	Records           map[string]RecordConfig `yaml:"records"`
+}
+
+// recordName returns the fully qualified name of the record configured
+// under key. The apex of the configured zone may be written as "@", and the
+// first label may be the wildcard "*".
+func recordName(key, zone string) (string, error) {
+	name := strings.TrimSuffix(key, ".")
+	if name == "@" {
+		if zone == "" {
+			return "", fmt.Errorf("record '@' requires the zone setting")
+		}
+		name = strings.TrimSuffix(zone, ".")
+	}
+
+	for i, label := range strings.Split(name, ".") {
+		if label == "*" && i == 0 {
+			continue
+		}
+		if !isHostnameLabel(label) {
+			return "", fmt.Errorf("invalid label '%s' in record name '%s'", label, name)
+		}
+	}
+	return name, nil
 }
 
-// extractZoneFromRecordName extracts the zone from a record name.
-// Assumes the hostname part is exactly one DNS label.
-// For example: "foo.example.com" -> "example.com"
-// Returns an error if the record name has fewer than 3 labels.
-func extractZoneFromRecordName(recordName string) (string, error) {
-	parts := strings.Split(recordName, ".")
-	if len(parts) < 3 {
-		return "", fmt.Errorf("record name '%s' must have at least 3 DNS labels (e.g., host.domain.tld)", recordName)
+// isHostnameLabel reports whether label consists of 1 to 63 letters,
+// digits, hyphens and underscores.
+func isHostnameLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
 	}
-	return strings.Join(parts[1:], "."), nil
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
 }
 
+// storageKey returns the file name the state of the record name is stored
+// under. The wildcard label is written as "%2A", which cannot clash with
+// another record name and needs no quoting in shells.
+func storageKey(name string) string {
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		return "%2A." + rest
+	}
+	return name
+}
+
+// recordZone returns the zone of the record name: the configured zone if
+// set, otherwise the registrable domain from the Public Suffix List. The
+// latter is only used if the zone cannot be looked up; it also rejects names
+// that are not below a public suffix.
+func recordZone(name, configured string) (string, error) {
+	if configured != "" {
+		zone := strings.ToLower(strings.TrimSuffix(configured, "."))
+		fqdn := strings.ToLower(strings.TrimSuffix(name, "."))
+		if fqdn != zone && !strings.HasSuffix(fqdn, "."+zone) {
+			return "", fmt.Errorf("record name '%s' is not in zone '%s'", name, configured)
+		}
+		return zone, nil
+	}
+
+	// A wildcard record lives in the zone of the name it covers.
+	return propagation.RegistrableDomain(strings.TrimPrefix(name, "*."))
+}
 
 func loadConfig(configPath string) (*Config, error) {
 	data, err := ioutil.ReadFile(configPath)
@@ -68,18 +190,74 @@ func loadConfig(configPath string) (*Config, error) {
 	if config.StoragePath == "" {
 		config.StoragePath = "/tmp/dns-updater"
 	}
+	if config.Watch.Debounce == 0 {
+		config.Watch.Debounce = 5 * time.Second
+	}
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry.MaxAttempts = 3
+	}
+	if config.Retry.InitialBackoff == 0 {
+		config.Retry.InitialBackoff = 2 * time.Second
+	}
+	if config.Retry.MaxBackoff == 0 {
+		config.Retry.MaxBackoff = 30 * time.Second
+	}
+	if config.Verify.Timeout == 0 {
+		config.Verify.Timeout = 2 * time.Minute
+	}
+	if config.Verify.Interval == 0 {
+		config.Verify.Interval = 5 * time.Second
+	}
+	if config.Retry.Jitter == nil {
+		jitter := 0.2
+		config.Retry.Jitter = &jitter
+	} else if *config.Retry.Jitter < 0 || *config.Retry.Jitter > 1 {
+		return nil, fmt.Errorf("retry jitter must be between 0 and 1, got %v", *config.Retry.Jitter)
+	}
 
 	for recordName, recordConfig := range config.Records {
-		if recordConfig.TTL == 0 {
-			rc := recordConfig
+		rc := recordConfig
+		if rc.TTL == 0 {
 			rc.TTL = 60 * time.Second
-			config.Records[recordName] = rc
 		}
+		types, err := parseRecordTypes(rc.Types)
+		if err != nil {
+			return nil, fmt.Errorf("record %s: %w", recordName, err)
+		}
+		rc.Types = types
+		config.Records[recordName] = rc
 	}
 
 	return &config, nil
 }
 
+// parseRecordTypes normalizes the configured record types to their DNS
+// spelling. An empty list defaults to A records only.
+func parseRecordTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return []string{dns.RecordTypeA}, nil
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, t := range types {
+		var recordType string
+		switch strings.ToLower(t) {
+		case "a":
+			recordType = dns.RecordTypeA
+		case "aaaa":
+			recordType = dns.RecordTypeAAAA
+		default:
+			return nil, fmt.Errorf("unsupported record type '%s' (expected a or aaaa)", t)
+		}
+		if !seen[recordType] {
+			seen[recordType] = true
+			result = append(result, recordType)
+		}
+	}
+	return result, nil
+}
+
 func main() {
 	configPath := flag.String("c", "/usr/local/etc/dns-updater.yaml", "path to configuration file")
 	flag.Parse()
@@ -93,52 +271,242 @@ func main() {
 		log.Fatal("no DNS records configured")
 	}
 
-	ipClient := ipify.NewClient(nil)
-	
+	// Only build the global clients for record types that use them, so
+	// IPv4-only sources don't fail configurations without AAAA records.
+	ipClients := make(map[string]ipify.ClientInterface)
+	for _, rc := range config.Records {
+		if rc.IPSources != nil {
+			continue
+		}
+		for _, recordType := range rc.Types {
+			if _, ok := ipClients[recordType]; ok {
+				continue
+			}
+			ipClient, err := newIPClient(config.IPSources, recordType)
+			if err != nil {
+				log.Fatalf("invalid ip_sources configuration for %s records: %v", recordType, err)
+			}
+			ipClients[recordType] = ipClient
+		}
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	var wg sync.WaitGroup
-	
-	for recordName, recordConfig := range config.Records {
-		wg.Add(1)
-		go func(name string, rConfig RecordConfig) {
-			defer wg.Done()
-			
-			zone, err := extractZoneFromRecordName(name)
-			if err != nil {
-				log.Printf("invalid record name %s: %v", name, err)
-				return
-			}
-			
-			dnsConfig := dns.Config{
-				Provider:           rConfig.Provider,
-				AWSAccessKeyID:     rConfig.AWSAccessKeyID,
-				AWSSecretAccessKey: rConfig.AWSSecretKey,
-				AWSRegion:          rConfig.AWSRegion,
-				CFAPIToken:         rConfig.CFAPIToken,
-				CFEmail:            rConfig.CFEmail,
-				CFAPIKey:           rConfig.CFAPIKey,
+	var watcher *netwatch.Watcher
+	if config.Watch.Enabled {
+		source, err := netwatch.NewNetlinkSource()
+		if err != nil {
+			log.Fatalf("failed to start network watcher: %v", err)
+		}
+		watcher = netwatch.NewWatcher(source, config.Watch.Debounce, config.Watch.Interfaces)
+		go func() {
+			if err := watcher.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("network watcher stopped: %v; relying on periodic updates", err)
 			}
+		}()
+	}
+
+	var verifier propagation.Interface
+	if config.Verify.Enabled {
+		v, err := propagation.NewVerifier(propagation.Config{
+			Resolvers: config.Verify.Resolvers,
+			Timeout:   config.Verify.Timeout,
+			Interval:  config.Verify.Interval,
+		})
+		if err != nil {
+			log.Fatalf("failed to set up propagation checks: %v", err)
+		}
+		verifier = v
+	}
+
+	cacheTTL := ipCacheTTL(config.UpdateInterval)
+	for recordType, ipClient := range ipClients {
+		ipClients[recordType] = ipify.NewCachedClient(ipClient, cacheTTL)
+	}
+
+	// Zones are found, and records of providers without an API to read
+	// them are read, with queries to the same resolvers the propagation
+	// check uses.
+	var finder service.ZoneFinder
+	var lookup dns.AuthoritativeLookup
+	if v, err := propagation.NewVerifier(propagation.Config{Resolvers: config.Verify.Resolvers}); err != nil {
+		log.Printf("zone lookups disabled: %v", err)
+	} else {
+		finder = v
+		lookup = v
+	}
 
-			dnsProvider, err := dns.NewProvider(dnsConfig)
+	// Records with identical provider settings share a provider, so the
+	// coordinator can batch their updates per zone.
+	providers := make(map[dns.Config]dns.Provider)
+	var services []*service.Service
+	for key, rConfig := range config.Records {
+		name, err := recordName(key, rConfig.Zone)
+		if err != nil {
+			log.Printf("invalid record name %s: %v", key, err)
+			continue
+		}
+		zone, err := recordZone(name, rConfig.Zone)
+		if err != nil {
+			log.Printf("invalid record name %s: %v", name, err)
+			continue
+		}
+		// The service looks the zone up at its first update rather than
+		// here, where the network may not be up yet.
+		var zoneFinder service.ZoneFinder
+		if rConfig.Zone == "" && finder != nil {
+			zone = ""
+			zoneFinder = finder
+		}
+
+		dnsConfig := dns.Config{
+			Provider:                rConfig.Provider,
+			AWSAccessKeyID:          rConfig.AWSAccessKeyID,
+			AWSSecretAccessKey:      rConfig.AWSSecretKey,
+			AWSRegion:               rConfig.AWSRegion,
+			AWSHostedZoneID:         rConfig.AWSHostedZoneID,
+			AWSProfile:              rConfig.AWSProfile,
+			AWSRoleARN:              rConfig.AWSRoleARN,
+			AWSExternalID:           rConfig.AWSExternalID,
+			AWSRoleSessionName:      rConfig.AWSRoleSessionName,
+			AWSWebIdentityTokenFile: rConfig.AWSWebIdentityTokenFile,
+			CFAPIToken:              rConfig.CFAPIToken,
+			CFEmail:                 rConfig.CFEmail,
+			CFAPIKey:                rConfig.CFAPIKey,
+			RFC2136Server:           rConfig.RFC2136Server,
+			RFC2136KeyName:          rConfig.RFC2136KeyName,
+			RFC2136Secret:           rConfig.RFC2136Secret,
+			RFC2136Algorithm:        rConfig.RFC2136Algorithm,
+			RFC2136Prerequisite:     rConfig.RFC2136Prerequisite,
+			PDNSAPIURL:              rConfig.PDNSAPIURL,
+			PDNSServerID:            rConfig.PDNSServerID,
+			PDNSAPIKey:              rConfig.PDNSAPIKey,
+			DOAPIToken:              rConfig.DOAPIToken,
+			HetznerAPIToken:         rConfig.HetznerAPIToken,
+			LinodeAPIToken:          rConfig.LinodeAPIToken,
+			DynDNSServer:            rConfig.DynDNSServer,
+			DynDNSUsername:          rConfig.DynDNSUsername,
+			DynDNSPassword:          rConfig.DynDNSPassword,
+			DuckDNSToken:            rConfig.DuckDNSToken,
+			Dynv6Token:              rConfig.Dynv6Token,
+			DeSECToken:              rConfig.DeSECToken,
+			GCPProject:              rConfig.GCPProject,
+			GCPCredentialsFile:      rConfig.GCPCredentialsFile,
+			AzureSubscriptionID:     rConfig.AzureSubscriptionID,
+			AzureResourceGroup:      rConfig.AzureResourceGroup,
+			AzureTenantID:           rConfig.AzureTenantID,
+			AzureClientID:           rConfig.AzureClientID,
+			AzureClientSecret:       rConfig.AzureClientSecret,
+		}
+
+		dnsProvider, ok := providers[dnsConfig]
+		if !ok {
+			dnsProvider, err = dns.NewProvider(dnsConfig)
 			if err != nil {
 				log.Printf("failed to create DNS provider for %s: %v", name, err)
-				return
+				continue
 			}
+			if lp, ok := dnsProvider.(dns.LookupProvider); ok && lookup != nil {
+				lp.SetLookup(lookup)
+			}
+			providers[dnsConfig] = dnsProvider
+		}
 
-			storageClient := storage.NewFileStorage(config.StoragePath + "/" + name)
+		if rConfig.AWSSetIdentifier != "" {
+			r53, ok := dnsProvider.(*dns.Route53Provider)
+			if !ok {
+				log.Printf("invalid settings for %s: aws_set_identifier requires the route53 provider", name)
+				continue
+			}
+			r53.SetRecordOptions(name, dns.Route53RecordOptions{SetIdentifier: rConfig.AWSSetIdentifier})
+		}
 
-			serviceConfig := service.Config{
-				Zone:       zone,
-				RecordName: name,
-				TTL:        rConfig.TTL,
+		if rConfig.CFProxied != nil || rConfig.CFComment != nil || rConfig.CFTags != nil {
+			cf, ok := dnsProvider.(*dns.CloudflareProvider)
+			if !ok {
+				log.Printf("invalid settings for %s: cf_proxied, cf_comment and cf_tags require the cloudflare provider", name)
+				continue
 			}
+			cf.SetRecordOptions(name, dns.CloudflareRecordOptions{
+				Proxied: rConfig.CFProxied,
+				Comment: rConfig.CFComment,
+				Tags:    rConfig.CFTags,
+			})
+		}
+
+		families, err := newFamilies(name, rConfig, config.StoragePath, ipClients, cacheTTL)
+		if err != nil {
+			log.Printf("invalid ip_sources for %s: %v", name, err)
+			continue
+		}
+
+		validator, err := service.NewValidator(rConfig.AllowCIDRs, rConfig.DenyCIDRs)
+		if err != nil {
+			log.Printf("invalid address ranges for %s: %v", name, err)
+			continue
+		}
+
+		serviceConfig := service.Config{
+			Zone:       zone,
+			ZoneFinder: zoneFinder,
+			RecordName: name,
+			TTL:        rConfig.TTL,
+			Validator:  validator,
+			Retry: service.RetryConfig{
+				MaxAttempts:    config.Retry.MaxAttempts,
+				InitialBackoff: config.Retry.InitialBackoff,
+				MaxBackoff:     config.Retry.MaxBackoff,
+				Jitter:         *config.Retry.Jitter,
+			},
+			ReconcileInterval: config.ReconcileInterval,
+			Propagation:       verifier,
+		}
+
+		services = append(services, service.New(dnsProvider, families, serviceConfig))
+	}
 
-			dnsService := service.New(dnsProvider, ipClient, storageClient, serviceConfig, config.UpdateInterval)
-			dnsService.Run(ctx)
-		}(recordName, recordConfig)
+	if len(services) == 0 {
+		log.Fatal("no DNS records could be set up")
 	}
 
-	wg.Wait()
+	coordinator := service.NewCoordinator(services, config.UpdateInterval)
+	if watcher != nil {
+		coordinator.SetTrigger(watcher.Subscribe())
+	}
+	coordinator.Run(ctx)
+}
+
+// ipCacheTTL returns how long a looked-up address is shared between
+// records. It is long enough to cover one cycle but well below the update
+// interval, so every cycle performs a fresh lookup.
+func ipCacheTTL(interval time.Duration) time.Duration {
+	ttl := 30 * time.Second
+	if half := interval / 2; half < ttl {
+		ttl = half
+	}
+	return ttl
+}
+
+// newFamilies builds the record families of a record, using the shared
+// clients in ipClients unless the record overrides its IP sources.
+func newFamilies(name string, rConfig RecordConfig, storagePath string, ipClients map[string]ipify.ClientInterface, cacheTTL time.Duration) ([]service.Family, error) {
+	statePath := storagePath + "/" + storageKey(name)
+	var families []service.Family
+	for _, recordType := range rConfig.Types {
+		ipClient := ipClients[recordType]
+		if rConfig.IPSources != nil {
+			override, err := newIPClient(*rConfig.IPSources, recordType)
+			if err != nil {
+				return nil, err
+			}
+			ipClient = ipify.NewCachedClient(override, cacheTTL)
+		}
+		families = append(families, service.Family{
+			RecordType: recordType,
+			IPClient:   ipClient,
+			Storage:    storage.NewFileStorage(storage.FamilyPath(statePath, recordType)),
+		})
+	}
+	return families, nil
 }
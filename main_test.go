@@ -1,64 +1,65 @@
 package main
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
-func TestExtractZoneFromRecordName(t *testing.T) {
+func TestRecordZone(t *testing.T) {
 	tests := []struct {
-		name        string
-		recordName  string
+		name         string
+		recordName   string
+		configured   string
 		expectedZone string
-		expectError bool
+		expectError  bool
 	}{
 		{
-			name:        "valid 3-label record",
-			recordName:  "foo.example.com",
-			expectedZone: "example.com",
-			expectError: false,
+			name:         "registrable domain",
+			recordName:   "host.example.co.uk",
+			expectedZone: "example.co.uk",
 		},
 		{
-			name:        "valid 4-label record",
-			recordName:  "foo.bar.example.com",
-			expectedZone: "bar.example.com",
-			expectError: false,
+			name:         "apex",
+			recordName:   "example.com",
+			expectedZone: "example.com",
 		},
 		{
-			name:        "valid 5-label record",
-			recordName:  "api.v1.prod.example.com",
-			expectedZone: "v1.prod.example.com",
-			expectError: false,
+			name:         "wildcard",
+			recordName:   "*.home.lab.example.net",
+			expectedZone: "example.net",
 		},
 		{
-			name:        "2-label record should error",
-			recordName:  "example.com",
-			expectError: true,
+			name:         "configured zone",
+			recordName:   "foo.bar.example.com",
+			configured:   "bar.example.com.",
+			expectedZone: "bar.example.com",
 		},
 		{
-			name:        "1-label record should error",
-			recordName:  "localhost",
+			name:        "configured zone not containing the record",
+			recordName:  "foo.example.com",
+			configured:  "example.org",
 			expectError: true,
 		},
 		{
-			name:        "empty string should error",
-			recordName:  "",
+			name:        "public suffix",
+			recordName:  "co.uk",
 			expectError: true,
 		},
 		{
-			name:        "subdomain case",
-			recordName:  "web.staging.example.org",
-			expectedZone: "staging.example.org",
-			expectError: false,
+			name:        "single label",
+			recordName:  "localhost",
+			expectError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			zone, err := extractZoneFromRecordName(tt.recordName)
+			zone, err := recordZone(tt.recordName, tt.configured)
 
 			if tt.expectError {
 				if err == nil {
-					t.Errorf("expected error for record name %q, but got none", tt.recordName)
+					t.Errorf("expected error for record name %q, but got zone %q", tt.recordName, zone)
 				}
 				return
 			}
@@ -73,4 +74,120 @@ func TestExtractZoneFromRecordName(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestRecordName(t *testing.T) {
+	tests := []struct {
+		key          string
+		zone         string
+		expectedName string
+		expectError  bool
+	}{
+		{key: "home.example.com", expectedName: "home.example.com"},
+		{key: "home.example.com.", expectedName: "home.example.com"},
+		{key: "_acme.example.com", expectedName: "_acme.example.com"},
+		{key: "@", zone: "example.com.", expectedName: "example.com"},
+		{key: "@", expectError: true},
+		{key: "*.example.com", expectedName: "*.example.com"},
+		{key: "*.home.example.com", zone: "example.com", expectedName: "*.home.example.com"},
+		{key: "home.*.example.com", expectError: true},
+		{key: "*home.example.com", expectError: true},
+		{key: "home..example.com", expectError: true},
+		{key: "home/../example.com", expectError: true},
+	}
+
+	for _, tt := range tests {
+		name, err := recordName(tt.key, tt.zone)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("expected error for record %q, but got name %q", tt.key, name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for record %q: %v", tt.key, err)
+			continue
+		}
+		if name != tt.expectedName {
+			t.Errorf("for record %q, expected name %q, got %q", tt.key, tt.expectedName, name)
+		}
+	}
+}
+
+func TestStorageKey(t *testing.T) {
+	tests := map[string]string{
+		"home.example.com":   "home.example.com",
+		"example.com":        "example.com",
+		"*.example.com":      "%2A.example.com",
+		"*.home.example.com": "%2A.home.example.com",
+	}
+	for name, expected := range tests {
+		if key := storageKey(name); key != expected {
+			t.Errorf("storageKey(%q) = %q, expected %q", name, key, expected)
+		}
+	}
+}
+
+func TestParseRecordTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "defaults to A",
+			expected: []string{"A"},
+		},
+		{
+			name:     "IPv6 only",
+			types:    []string{"aaaa"},
+			expected: []string{"AAAA"},
+		},
+		{
+			name:     "dual stack with duplicates",
+			types:    []string{"a", "AAAA", "A"},
+			expected: []string{"A", "AAAA"},
+		},
+		{
+			name:        "unsupported type",
+			types:       []string{"cname"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types, err := parseRecordTypes(tt.types)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected error for types %v, but got none", tt.types)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(types, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, types)
+			}
+		})
+	}
+}
+
+func TestIPCacheTTL(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		expected time.Duration
+	}{
+		{interval: 2 * time.Minute, expected: 30 * time.Second},
+		{interval: 20 * time.Second, expected: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := ipCacheTTL(tt.interval); got != tt.expected {
+			t.Errorf("interval %s: expected %s, got %s", tt.interval, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// rtnetlink multicast groups from linux/rtnetlink.h.
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+// NetlinkSource implements EventSource using rtnetlink address and route
+// notifications.
+type NetlinkSource struct{}
+
+// NewNetlinkSource creates a new NetlinkSource.
+func NewNetlinkSource() (*NetlinkSource, error) {
+	return &NetlinkSource{}, nil
+}
+
+// Events subscribes to address and route changes.
+func (s *NetlinkSource) Events(ctx context.Context) (<-chan Event, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv4Route | rtmgrpIPv6IfAddr | rtmgrpIPv6Route,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// Wake up periodically so cancellation is noticed without a message.
+	tv := syscall.NsecToTimeval(int64(500 * 1e6))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer syscall.Close(fd)
+
+		send := func(e Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		buf := make([]byte, syscall.Getpagesize())
+		for ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				switch {
+				case errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR):
+					continue
+				case errors.Is(err, syscall.ENOBUFS):
+					// The socket overflowed and notifications were
+					// dropped; the socket itself keeps working.
+					if !send(Event{Kind: Resync}) {
+						return
+					}
+					continue
+				default:
+					send(Event{Err: fmt.Errorf("netlink receive failed: %w", err)})
+					return
+				}
+			}
+
+			msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, m := range msgs {
+				e, ok := parseMessage(m)
+				if !ok {
+					continue
+				}
+				if !send(e) {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// parseMessage converts an rtnetlink message into an Event. Route changes
+// other than default routes are ignored.
+func parseMessage(m syscall.NetlinkMessage) (Event, bool) {
+	switch m.Header.Type {
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(m.Data) < syscall.SizeofIfAddrmsg {
+			return Event{}, false
+		}
+		index := int(binary.NativeEndian.Uint32(m.Data[4:8]))
+		return newEvent(AddressChanged, index), true
+	case syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+		if len(m.Data) < syscall.SizeofRtMsg || m.Data[1] != 0 {
+			return Event{}, false
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&m)
+		if err != nil {
+			return Event{}, false
+		}
+		index := 0
+		for _, a := range attrs {
+			if a.Attr.Type == syscall.RTA_OIF && len(a.Value) >= 4 {
+				index = int(binary.NativeEndian.Uint32(a.Value))
+			}
+		}
+		return newEvent(RouteChanged, index), true
+	}
+	return Event{}, false
+}
+
+func newEvent(kind EventKind, index int) Event {
+	e := Event{Kind: kind, Index: index}
+	if iface, err := net.InterfaceByIndex(index); err == nil {
+		e.Interface = iface.Name
+	}
+	return e
+}
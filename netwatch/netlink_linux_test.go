@@ -0,0 +1,82 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+)
+
+func netlinkMessage(msgType uint16, data []byte) syscall.NetlinkMessage {
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: msgType, Len: uint32(syscall.NLMSG_HDRLEN + len(data))},
+		Data:   data,
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	addr := make([]byte, syscall.SizeofIfAddrmsg)
+	binary.NativeEndian.PutUint32(addr[4:8], 7)
+
+	defaultRoute := make([]byte, syscall.SizeofRtMsg+8)
+	binary.NativeEndian.PutUint16(defaultRoute[syscall.SizeofRtMsg:], 8)
+	binary.NativeEndian.PutUint16(defaultRoute[syscall.SizeofRtMsg+2:], syscall.RTA_OIF)
+	binary.NativeEndian.PutUint32(defaultRoute[syscall.SizeofRtMsg+4:], 9)
+
+	subnetRoute := make([]byte, syscall.SizeofRtMsg)
+	subnetRoute[1] = 24
+
+	tests := []struct {
+		name     string
+		msg      syscall.NetlinkMessage
+		expected Event
+		ok       bool
+	}{
+		{
+			name:     "new address",
+			msg:      netlinkMessage(syscall.RTM_NEWADDR, addr),
+			expected: Event{Kind: AddressChanged, Index: 7},
+			ok:       true,
+		},
+		{
+			name:     "deleted address",
+			msg:      netlinkMessage(syscall.RTM_DELADDR, addr),
+			expected: Event{Kind: AddressChanged, Index: 7},
+			ok:       true,
+		},
+		{
+			name:     "default route",
+			msg:      netlinkMessage(syscall.RTM_NEWROUTE, defaultRoute),
+			expected: Event{Kind: RouteChanged, Index: 9},
+			ok:       true,
+		},
+		{
+			name: "subnet route is ignored",
+			msg:  netlinkMessage(syscall.RTM_NEWROUTE, subnetRoute),
+		},
+		{
+			name: "truncated message is ignored",
+			msg:  netlinkMessage(syscall.RTM_NEWADDR, addr[:4]),
+		},
+		{
+			name: "link message is ignored",
+			msg:  netlinkMessage(syscall.RTM_NEWLINK, addr),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := parseMessage(tt.msg)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if e.Kind != tt.expected.Kind || e.Index != tt.expected.Index {
+				t.Errorf("expected %+v, got %+v", tt.expected, e)
+			}
+		})
+	}
+}
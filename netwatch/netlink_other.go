@@ -0,0 +1,21 @@
+//go:build !linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+)
+
+// NetlinkSource is only available on Linux.
+type NetlinkSource struct{}
+
+// NewNetlinkSource returns an error on platforms without rtnetlink.
+func NewNetlinkSource() (*NetlinkSource, error) {
+	return nil, errors.New("netlink address notifications are only supported on Linux")
+}
+
+// Events always fails on platforms without rtnetlink.
+func (s *NetlinkSource) Events(ctx context.Context) (<-chan Event, error) {
+	return nil, errors.New("netlink address notifications are only supported on Linux")
+}
@@ -0,0 +1,141 @@
+// Package netwatch turns network change notifications into debounced
+// update triggers.
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EventKind identifies what changed on the host.
+type EventKind int
+
+const (
+	// AddressChanged is reported when an interface address is added or removed.
+	AddressChanged EventKind = iota
+	// RouteChanged is reported when a default route is added or removed.
+	RouteChanged
+	// Resync is reported when notifications were lost, e.g. because the
+	// receive buffer overflowed, so any address may have changed.
+	Resync
+)
+
+// errSourceClosed is returned by Run when the source stops without an
+// error while the context is still active.
+var errSourceClosed = errors.New("network event source closed")
+
+// Event describes a single network change.
+type Event struct {
+	Kind      EventKind
+	Index     int    // interface index
+	Interface string // interface name, empty if it no longer exists
+	// Err is set on the last event of a source that failed. The source
+	// closes the channel after sending it.
+	Err error
+}
+
+// EventSource defines the behavior for receiving network change events.
+type EventSource interface {
+	// Events streams events until ctx is cancelled or the source fails,
+	// then closes the channel. A failure is reported as a final event with
+	// Err set.
+	Events(ctx context.Context) (<-chan Event, error)
+}
+
+// Watcher fans debounced network change events out to its subscribers.
+type Watcher struct {
+	source     EventSource
+	debounce   time.Duration
+	interfaces map[string]bool
+
+	mu          sync.Mutex
+	subscribers []chan struct{}
+}
+
+// NewWatcher creates a new Watcher. Events are coalesced until no further
+// event has arrived for the debounce period. If interfaces is non-empty,
+// only events on those interfaces trigger an update.
+func NewWatcher(source EventSource, debounce time.Duration, interfaces []string) *Watcher {
+	w := &Watcher{
+		source:   source,
+		debounce: debounce,
+	}
+	if len(interfaces) > 0 {
+		w.interfaces = make(map[string]bool)
+		for _, name := range interfaces {
+			w.interfaces[name] = true
+		}
+	}
+	return w
+}
+
+// Subscribe returns a channel that receives a value after each debounced
+// burst of relevant events. Triggers are dropped while a previous one is
+// still pending, so a slow subscriber sees at most one queued trigger.
+func (w *Watcher) Subscribe() <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	w.mu.Lock()
+	w.subscribers = append(w.subscribers, ch)
+	w.mu.Unlock()
+	return ch
+}
+
+// Run consumes events from the source until ctx is cancelled. It returns a
+// non-nil error if the source fails or stops before that.
+func (w *Watcher) Run(ctx context.Context) error {
+	events, err := w.source.Events(ctx)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(w.debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return errSourceClosed
+			}
+			if e.Err != nil {
+				return e.Err
+			}
+			if w.relevant(e) {
+				timer.Reset(w.debounce)
+			}
+		case <-timer.C:
+			w.notify()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// relevant reports whether the event concerns a watched interface. Events
+// for interfaces that have already disappeared are always relevant.
+func (w *Watcher) relevant(e Event) bool {
+	if w.interfaces == nil || e.Interface == "" {
+		return true
+	}
+	return w.interfaces[e.Interface]
+}
+
+// notify sends a trigger to every subscriber without blocking.
+func (w *Watcher) notify() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	events chan Event
+	err    error
+}
+
+func (f *fakeSource) Events(ctx context.Context) (<-chan Event, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.events, nil
+}
+
+func startWatcher(t *testing.T, w *Watcher) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = w.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cancel
+}
+
+func expectTrigger(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("expected trigger, got none")
+	}
+}
+
+func expectNoTrigger(t *testing.T, ch <-chan struct{}, wait time.Duration) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatal("unexpected trigger")
+	case <-time.After(wait):
+	}
+}
+
+func TestWatcherDebouncesBursts(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	w := NewWatcher(source, 50*time.Millisecond, nil)
+	trigger := w.Subscribe()
+	startWatcher(t, w)
+
+	for i := 0; i < 5; i++ {
+		source.events <- Event{Kind: AddressChanged, Index: 2, Interface: "ppp0"}
+	}
+
+	expectTrigger(t, trigger)
+	expectNoTrigger(t, trigger, 150*time.Millisecond)
+}
+
+func TestWatcherFiltersInterfaces(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	w := NewWatcher(source, 10*time.Millisecond, []string{"ppp0"})
+	trigger := w.Subscribe()
+	startWatcher(t, w)
+
+	source.events <- Event{Kind: AddressChanged, Index: 3, Interface: "docker0"}
+	expectNoTrigger(t, trigger, 100*time.Millisecond)
+
+	source.events <- Event{Kind: RouteChanged, Index: 2, Interface: "ppp0"}
+	expectTrigger(t, trigger)
+
+	// ppp0 disappearing on disconnect is reported without a name.
+	source.events <- Event{Kind: AddressChanged, Index: 2}
+	expectTrigger(t, trigger)
+}
+
+func TestWatcherNotifiesAllSubscribers(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	w := NewWatcher(source, 10*time.Millisecond, nil)
+	first := w.Subscribe()
+	second := w.Subscribe()
+	startWatcher(t, w)
+
+	source.events <- Event{Kind: AddressChanged, Index: 2, Interface: "eth0"}
+
+	expectTrigger(t, first)
+	expectTrigger(t, second)
+}
+
+func TestWatcherSourceError(t *testing.T) {
+	errSource := errors.New("netlink unavailable")
+	w := NewWatcher(&fakeSource{err: errSource}, time.Millisecond, nil)
+
+	if err := w.Run(context.Background()); !errors.Is(err, errSource) {
+		t.Fatalf("expected source error, got %v", err)
+	}
+}
+
+func TestWatcherStopsWhenSourceCloses(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	w := NewWatcher(source, time.Millisecond, nil)
+	close(source.events)
+
+	done := make(chan error)
+	go func() {
+		done <- w.Run(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error when the source closes before cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop after the source closed")
+	}
+}
+
+func TestWatcherStopsWhenSourceFails(t *testing.T) {
+	errRecv := errors.New("netlink receive failed")
+	source := &fakeSource{events: make(chan Event, 1)}
+	w := NewWatcher(source, time.Millisecond, nil)
+	source.events <- Event{Err: errRecv}
+
+	if err := w.Run(context.Background()); !errors.Is(err, errRecv) {
+		t.Fatalf("expected source failure, got %v", err)
+	}
+}
+
+func TestWatcherResync(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	w := NewWatcher(source, 10*time.Millisecond, []string{"ppp0"})
+	trigger := w.Subscribe()
+	startWatcher(t, w)
+
+	source.events <- Event{Kind: Resync}
+	expectTrigger(t, trigger)
+}
@@ -0,0 +1,301 @@
+// Package propagation checks that a published record is served by the
+// authoritative name servers of its zone, and finds the zone of a name.
+package propagation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/epsilonrhorho/dns-updater/internal/hostport"
+)
+
+// Interface defines the behavior for waiting on a change to propagate.
+type Interface interface {
+	// Wait blocks until every authoritative name server of zone answers
+	// with ip for the record, or returns an error describing the servers
+	// that do not.
+	Wait(ctx context.Context, zone, name, recordType, ip string) error
+}
+
+// Config configures a Verifier.
+type Config struct {
+	// Resolvers are the recursive resolvers used to find the name servers
+	// of a zone. If empty, the resolvers from /etc/resolv.conf are used.
+	Resolvers []string
+	// Timeout bounds how long Wait polls the name servers.
+	Timeout time.Duration
+	// Interval is the delay between two rounds of queries.
+	Interval time.Duration
+}
+
+// Verifier implements Interface by querying the authoritative name servers
+// directly, bypassing any caches.
+type Verifier struct {
+	client    *dns.Client
+	resolvers []string
+	timeout   time.Duration
+	interval  time.Duration
+	port      string // port the name servers are queried on
+}
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(config Config) (*Verifier, error) {
+	resolvers := config.Resolvers
+	if len(resolvers) == 0 {
+		conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read system resolvers: %w", err)
+		}
+		for _, server := range conf.Servers {
+			resolvers = append(resolvers, net.JoinHostPort(server, conf.Port))
+		}
+	}
+	if len(resolvers) == 0 {
+		return nil, errors.New("no resolvers configured")
+	}
+
+	normalized := make([]string, len(resolvers))
+	for i, r := range resolvers {
+		normalized[i] = hostport.WithDefaultPort(r, "53")
+	}
+	return &Verifier{
+		client:    &dns.Client{Timeout: 5 * time.Second},
+		resolvers: normalized,
+		timeout:   config.Timeout,
+		interval:  config.Interval,
+		port:      "53",
+	}, nil
+}
+
+// nameServer is an authoritative name server of a zone.
+type nameServer struct {
+	host  string
+	addrs []string // host:port
+}
+
+// Wait polls the authoritative name servers of zone every interval until
+// all of them answer with ip, or the timeout expires. A name server counts
+// as up to date as soon as one of its addresses serves ip.
+func (v *Verifier) Wait(ctx context.Context, zone, name, recordType, ip string) error {
+	qtype, ok := dns.StringToType[recordType]
+	if !ok || (qtype != dns.TypeA && qtype != dns.TypeAAAA) {
+		return fmt.Errorf("unsupported record type: %s", recordType)
+	}
+	fqdn := dns.Fqdn(name)
+
+	if v.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+	}
+
+	var servers []nameServer
+	var err error
+	for {
+		if servers == nil {
+			if servers, err = v.nameServers(ctx, zone); err != nil {
+				err = fmt.Errorf("failed to find name servers of %s: %w", zone, err)
+			}
+		}
+		if servers != nil {
+			servers = v.outdated(ctx, servers, fqdn, qtype, ip)
+			if len(servers) == 0 {
+				return nil
+			}
+			hosts := make([]string, len(servers))
+			for i, ns := range servers {
+				hosts[i] = ns.host
+			}
+			err = fmt.Errorf("%s not yet served by %s", ip, strings.Join(hosts, ", "))
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(v.interval):
+		}
+	}
+}
+
+// outdated returns the name servers that do not serve ip yet.
+func (v *Verifier) outdated(ctx context.Context, servers []nameServer, fqdn string, qtype uint16, ip string) []nameServer {
+	var result []nameServer
+	for _, ns := range servers {
+		if !v.serves(ctx, ns, fqdn, qtype, ip) {
+			result = append(result, ns)
+		}
+	}
+	return result
+}
+
+// serves reports whether the name server answers the query with ip. Further
+// addresses of the name, e.g. of other hosts, do not matter.
+func (v *Verifier) serves(ctx context.Context, ns nameServer, fqdn string, qtype uint16, ip string) bool {
+	addrs, err := v.query(ctx, ns, fqdn, qtype)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if addr.Equal(net.ParseIP(ip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// query asks the addresses of the name server in turn for the record and
+// returns the answer of the first one that responds. A name that does not
+// exist has no addresses.
+func (v *Verifier) query(ctx context.Context, ns nameServer, fqdn string, qtype uint16) ([]net.IP, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(fqdn, qtype)
+	msg.RecursionDesired = false
+
+	var errs []error
+	for _, addr := range ns.addrs {
+		resp, _, err := v.client.ExchangeContext(ctx, msg, addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ns.host, err))
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			errs = append(errs, fmt.Errorf("%s: name server returned %s", ns.host, dns.RcodeToString[resp.Rcode]))
+			continue
+		}
+		return answerAddrs(resp, qtype), nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no address for name server %s", ns.host)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Lookup returns the addresses of the A or AAAA record as served by the
+// first authoritative name server of zone that answers. Unlike a recursive
+// resolver, it never returns a cached answer.
+func (v *Verifier) Lookup(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	qtype, ok := dns.StringToType[recordType]
+	if !ok || (qtype != dns.TypeA && qtype != dns.TypeAAAA) {
+		return nil, fmt.Errorf("unsupported record type: %s", recordType)
+	}
+
+	servers, err := v.nameServers(ctx, zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find name servers of %s: %w", zone, err)
+	}
+
+	var errs []error
+	for _, ns := range servers {
+		ips, err := v.query(ctx, ns, dns.Fqdn(name), qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		addrs := make([]string, len(ips))
+		for i, ip := range ips {
+			addrs[i] = ip.String()
+		}
+		return addrs, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// nameServers looks up the authoritative name servers of zone and their
+// addresses, preferring the glue records of the response.
+func (v *Verifier) nameServers(ctx context.Context, zone string) ([]nameServer, error) {
+	resp, err := v.resolve(ctx, dns.Fqdn(zone), dns.TypeNS)
+	if err != nil {
+		return nil, err
+	}
+
+	glue := make(map[string][]net.IP)
+	for _, rr := range resp.Extra {
+		host := strings.ToLower(rr.Header().Name)
+		switch r := rr.(type) {
+		case *dns.A:
+			glue[host] = append(glue[host], r.A)
+		case *dns.AAAA:
+			glue[host] = append(glue[host], r.AAAA)
+		}
+	}
+
+	var servers []nameServer
+	for _, rr := range resp.Answer {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		ips := glue[strings.ToLower(ns.Ns)]
+		if len(ips) == 0 {
+			ips = v.lookupHost(ctx, ns.Ns)
+		}
+		server := nameServer{host: strings.TrimSuffix(ns.Ns, ".")}
+		for _, addr := range ips {
+			server.addrs = append(server.addrs, net.JoinHostPort(addr.String(), v.port))
+		}
+		servers = append(servers, server)
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no NS records for %s", zone)
+	}
+	return servers, nil
+}
+
+// lookupHost returns the IPv4 and IPv6 addresses of host.
+func (v *Verifier) lookupHost(ctx context.Context, host string) []net.IP {
+	var ips []net.IP
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := v.resolve(ctx, host, qtype)
+		if err != nil {
+			continue
+		}
+		ips = append(ips, answerAddrs(resp, qtype)...)
+	}
+	return ips
+}
+
+// resolve queries the resolvers in order and returns the first successful
+// response.
+func (v *Verifier) resolve(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+
+	var errs []error
+	for _, resolver := range v.resolvers {
+		resp, _, err := v.client.ExchangeContext(ctx, msg, resolver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", resolver, err))
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			errs = append(errs, fmt.Errorf("%s: resolver returned %s", resolver, dns.RcodeToString[resp.Rcode]))
+			continue
+		}
+		return resp, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// answerAddrs returns the addresses of the given type in the answer.
+func answerAddrs(resp *dns.Msg, qtype uint16) []net.IP {
+	var ips []net.IP
+	for _, rr := range resp.Answer {
+		switch r := rr.(type) {
+		case *dns.A:
+			if qtype == dns.TypeA {
+				ips = append(ips, r.A)
+			}
+		case *dns.AAAA:
+			if qtype == dns.TypeAAAA {
+				ips = append(ips, r.AAAA)
+			}
+		}
+	}
+	return ips
+}
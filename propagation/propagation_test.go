@@ -0,0 +1,176 @@
+package propagation
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fakeZone is an in-process DNS server acting both as the recursive
+// resolver and as the authoritative name servers of example.com and of
+// duckdns.org, a private suffix. ns1 is announced with glue, ns2 has to be
+// resolved separately.
+type fakeZone struct {
+	mu          sync.Mutex
+	served      string // address served for home.example.com
+	other       string // further address served for home.example.com, if any
+	noNS        bool
+	noAuthority bool // omit the SOA record from negative answers
+}
+
+func (z *fakeZone) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	q := r.Question[0]
+	hdr := func(name string, rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: 60}
+	}
+	loopback := net.ParseIP("127.0.0.1")
+
+	soa := &dns.SOA{Hdr: hdr("example.com.", dns.TypeSOA), Ns: "ns1.example.net.", Mbox: "hostmaster.example.com.", Minttl: 60}
+	duckSOA := &dns.SOA{Hdr: hdr("duckdns.org.", dns.TypeSOA), Ns: "ns1.duckdns.org.", Mbox: "hostmaster.duckdns.org.", Minttl: 60}
+
+	switch {
+	case q.Qtype == dns.TypeSOA && q.Name == "duckdns.org.":
+		m.Answer = []dns.RR{duckSOA}
+	case q.Qtype == dns.TypeSOA && dns.IsSubDomain("duckdns.org.", q.Name):
+		if !z.noAuthority {
+			m.Ns = []dns.RR{duckSOA}
+		}
+	case q.Qtype == dns.TypeSOA && q.Name == "example.com.":
+		m.Answer = []dns.RR{soa}
+	case q.Qtype == dns.TypeSOA && dns.IsSubDomain("example.com.", q.Name):
+		if q.Name != "home.example.com." {
+			m.Rcode = dns.RcodeNameError
+		}
+		if !z.noAuthority {
+			m.Ns = []dns.RR{soa}
+		}
+	case q.Qtype == dns.TypeNS && q.Name == "example.com." && !z.noNS:
+		m.Answer = []dns.RR{
+			&dns.NS{Hdr: hdr(q.Name, dns.TypeNS), Ns: "ns1.example.net."},
+			&dns.NS{Hdr: hdr(q.Name, dns.TypeNS), Ns: "ns2.example.net."},
+		}
+		m.Extra = []dns.RR{&dns.A{Hdr: hdr("ns1.example.net.", dns.TypeA), A: loopback}}
+	case q.Qtype == dns.TypeA && q.Name == "ns2.example.net.":
+		m.Answer = []dns.RR{&dns.A{Hdr: hdr(q.Name, dns.TypeA), A: loopback}}
+	case q.Qtype == dns.TypeA && q.Name == "home.example.com." && !r.RecursionDesired:
+		m.Authoritative = true
+		if z.served != "" {
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr(q.Name, dns.TypeA), A: net.ParseIP(z.served)}}
+		}
+		if z.other != "" {
+			m.Answer = append(m.Answer, &dns.A{Hdr: hdr(q.Name, dns.TypeA), A: net.ParseIP(z.other)})
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+// set changes the address served by the name servers.
+func (z *fakeZone) set(ip string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.served = ip
+}
+
+func startFakeZone(t *testing.T, z *fakeZone) *Verifier {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on udp4: %v", err)
+	}
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(z.serveDNS)}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	addr := pc.LocalAddr().String()
+	v, err := NewVerifier(Config{Resolvers: []string{addr}, Timeout: time.Second, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, v.port, _ = net.SplitHostPort(addr)
+	return v
+}
+
+func TestVerifier_WaitPropagated(t *testing.T) {
+	z := &fakeZone{served: "198.51.100.1"}
+	v := startFakeZone(t, z)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		z.set("203.0.113.1")
+	}()
+
+	if err := v.Wait(context.Background(), "example.com", "home.example.com", "A", "203.0.113.1"); err != nil {
+		t.Fatalf("expected the address to propagate, got %v", err)
+	}
+}
+
+func TestVerifier_WaitPropagatedWithOtherAddresses(t *testing.T) {
+	v := startFakeZone(t, &fakeZone{served: "203.0.113.1", other: "198.51.100.7"})
+	v.timeout = 100 * time.Millisecond
+
+	if err := v.Wait(context.Background(), "example.com", "home.example.com", "A", "203.0.113.1"); err != nil {
+		t.Fatalf("expected the address to count as propagated, got %v", err)
+	}
+}
+
+func TestVerifier_WaitPending(t *testing.T) {
+	z := &fakeZone{served: "198.51.100.1"}
+	v := startFakeZone(t, z)
+	v.timeout = 100 * time.Millisecond
+
+	err := v.Wait(context.Background(), "example.com", "home.example.com", "A", "203.0.113.1")
+	if err == nil {
+		t.Fatal("expected pending propagation error, got nil")
+	}
+	if !strings.Contains(err.Error(), "ns1.example.net, ns2.example.net") {
+		t.Errorf("expected both name servers to be reported, got %v", err)
+	}
+}
+
+func TestVerifier_WaitNoNameServers(t *testing.T) {
+	v := startFakeZone(t, &fakeZone{noNS: true})
+	v.timeout = 100 * time.Millisecond
+
+	err := v.Wait(context.Background(), "example.com", "home.example.com", "A", "203.0.113.1")
+	if err == nil || !strings.Contains(err.Error(), "failed to find name servers") {
+		t.Fatalf("expected name server lookup error, got %v", err)
+	}
+}
+
+func TestVerifier_Lookup(t *testing.T) {
+	v := startFakeZone(t, &fakeZone{served: "198.51.100.1"})
+
+	addrs, err := v.Lookup(context.Background(), "example.com", "home.example.com", "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "198.51.100.1" {
+		t.Errorf("expected [198.51.100.1], got %v", addrs)
+	}
+
+	addrs, err = v.Lookup(context.Background(), "example.com", "home.example.com", "AAAA")
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("expected no AAAA addresses, got %v, %v", addrs, err)
+	}
+}
+
+func TestVerifier_WaitUnsupportedType(t *testing.T) {
+	v := &Verifier{}
+	if err := v.Wait(context.Background(), "example.com", "home.example.com", "MX", "203.0.113.1"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
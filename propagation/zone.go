@@ -0,0 +1,91 @@
+package propagation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// RegistrableDomain returns the domain of name that was registered below a
+// public suffix, e.g. "example.co.uk" for "home.example.co.uk", according
+// to the Public Suffix List.
+func RegistrableDomain(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return "", fmt.Errorf("%s is not below a public suffix: %w", name, err)
+	}
+	return domain, nil
+}
+
+// FindZone returns the zone name belongs to: the closest enclosing name
+// that owns an SOA record, as seen by the resolvers. The search stops at the
+// registrable domain of name, so a public suffix such as "com" or "co.uk"
+// is never returned. Private suffixes, such as "duckdns.org", are the
+// exception: the service operating one usually serves the names below it
+// from a single zone, so the search may reach the suffix itself.
+func (v *Verifier) FindZone(ctx context.Context, name string) (string, error) {
+	domain, err := RegistrableDomain(name)
+	if err != nil {
+		return "", err
+	}
+	apex := dns.Fqdn(domain)
+	if suffix, icann := publicsuffix.PublicSuffix(strings.ToLower(strings.TrimSuffix(name, "."))); !icann && strings.Contains(suffix, ".") {
+		apex = dns.Fqdn(suffix)
+	}
+
+	candidate := dns.Fqdn(strings.ToLower(name))
+	for {
+		zone, err := v.soaOwner(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to find zone of %s: %w", name, err)
+		}
+		if zone != "" && dns.IsSubDomain(apex, zone) {
+			return strings.TrimSuffix(zone, "."), nil
+		}
+		if candidate == apex {
+			return "", fmt.Errorf("failed to find zone of %s: no SOA record up to %s", name, strings.TrimSuffix(apex, "."))
+		}
+		next, _ := dns.NextLabel(candidate, 0)
+		candidate = candidate[next:]
+	}
+}
+
+// soaOwner queries the SOA record of name and returns the zone the answer
+// names: name itself if it owns the SOA record, otherwise the owner of the
+// SOA record in the authority section that accompanies negative answers.
+// It returns "" if the response has neither.
+func (v *Verifier) soaOwner(ctx context.Context, name string) (string, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, dns.TypeSOA)
+
+	var errs []error
+	for _, resolver := range v.resolvers {
+		resp, _, err := v.client.ExchangeContext(ctx, msg, resolver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", resolver, err))
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			errs = append(errs, fmt.Errorf("%s: resolver returned %s", resolver, dns.RcodeToString[resp.Rcode]))
+			continue
+		}
+
+		for _, rr := range resp.Answer {
+			if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, name) {
+				return strings.ToLower(soa.Hdr.Name), nil
+			}
+		}
+		for _, rr := range resp.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				return strings.ToLower(soa.Hdr.Name), nil
+			}
+		}
+		return "", nil
+	}
+	return "", errors.Join(errs...)
+}
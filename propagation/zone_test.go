@@ -0,0 +1,81 @@
+package propagation
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "baz.subdomain.example.com", expected: "example.com"},
+		{name: "example.com.", expected: "example.com"},
+		{name: "Home.Example.co.uk", expected: "example.co.uk"},
+		{name: "myhome.duckdns.org", expected: "myhome.duckdns.org"},
+		{name: "co.uk"},
+		{name: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domain, err := RegistrableDomain(tt.name)
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("expected error, got %q", domain)
+				}
+				return
+			}
+			if err != nil || domain != tt.expected {
+				t.Errorf("expected %q, got %q, %v", tt.expected, domain, err)
+			}
+		})
+	}
+}
+
+func TestVerifier_FindZone(t *testing.T) {
+	tests := []struct {
+		name        string
+		noAuthority bool
+	}{
+		{name: "authority section"},
+		{name: "walk up without authority section", noAuthority: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := startFakeZone(t, &fakeZone{noAuthority: tt.noAuthority})
+
+			for _, name := range []string{"example.com", "home.example.com", "baz.subdomain.example.com."} {
+				zone, err := v.FindZone(context.Background(), name)
+				if err != nil || zone != "example.com" {
+					t.Errorf("%s: expected example.com, got %q, %v", name, zone, err)
+				}
+			}
+
+			// duckdns.org is a private suffix, so myhome.duckdns.org is a
+			// registrable domain of its own, served from the zone of the
+			// suffix.
+			for _, name := range []string{"myhome.duckdns.org", "www.myhome.duckdns.org"} {
+				zone, err := v.FindZone(context.Background(), name)
+				if err != nil || zone != "duckdns.org" {
+					t.Errorf("%s: expected duckdns.org, got %q, %v", name, zone, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifier_FindZoneErrors(t *testing.T) {
+	v := startFakeZone(t, &fakeZone{})
+
+	if _, err := v.FindZone(context.Background(), "co.uk"); err == nil {
+		t.Error("expected error for public suffix")
+	}
+	_, err := v.FindZone(context.Background(), "home.example.org")
+	if err == nil || !strings.Contains(err.Error(), "no SOA record up to example.org") {
+		t.Errorf("expected error for unknown zone, got %v", err)
+	}
+}
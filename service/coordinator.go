@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+)
+
+// invalidator is implemented by IP clients that cache their answers, such
+// as ipify.CachedClient.
+type invalidator interface {
+	Invalidate()
+}
+
+// Coordinator drives many services from a single ticker, so every record is
+// reconciled in the same cycle. When the services share a caching IP client
+// the public address is looked up once per cycle instead of once per record.
+type Coordinator struct {
+	services []*Service
+	interval time.Duration
+	trigger  <-chan struct{}
+}
+
+// NewCoordinator creates a Coordinator that updates services every interval.
+func NewCoordinator(services []*Service, interval time.Duration) *Coordinator {
+	return &Coordinator{
+		services: services,
+		interval: interval,
+	}
+}
+
+// SetTrigger makes Run start a cycle whenever a value is received on
+// trigger, in addition to the periodic cycles. Cached addresses are
+// discarded first, since the trigger signals a network change.
+func (c *Coordinator) SetTrigger(trigger <-chan struct{}) {
+	c.trigger = trigger
+}
+
+// batchKey identifies changes that can be published together.
+type batchKey struct {
+	provider dns.Provider
+	zone     string
+}
+
+// Update runs one cycle. All services are checked concurrently, then the
+// resulting changes are grouped by DNS provider and zone so that records
+// sharing a provider account and zone are published in a single batch.
+func (c *Coordinator) Update(ctx context.Context) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[*Service][]error)
+	groups := make(map[batchKey][]change)
+	var keys []batchKey
+
+	for _, s := range c.services {
+		wg.Add(1)
+		go func(s *Service) {
+			defer wg.Done()
+			changes, planErrs := s.plan(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			errs[s] = append(errs[s], planErrs...)
+			for _, ch := range changes {
+				key := batchKey{provider: s.dnsProvider, zone: s.config.Zone}
+				if _, ok := groups[key]; !ok {
+					keys = append(keys, key)
+				}
+				groups[key] = append(groups[key], ch)
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	for _, key := range keys {
+		wg.Add(1)
+		go func(changes []change) {
+			defer wg.Done()
+			results := applyChanges(ctx, changes)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i, err := range results {
+				if err != nil {
+					s := changes[i].service
+					errs[s] = append(errs[s], err)
+				}
+			}
+		}(groups[key])
+	}
+	wg.Wait()
+
+	for _, s := range c.services {
+		if err := errors.Join(errs[s]...); err != nil {
+			log.Printf("%s: update failed: %v", s.config.RecordName, err)
+		}
+	}
+}
+
+// invalidate discards the addresses cached by the services' IP clients.
+func (c *Coordinator) invalidate() {
+	for _, s := range c.services {
+		for _, f := range s.families {
+			if inv, ok := f.IPClient.(invalidator); ok {
+				inv.Invalidate()
+			}
+		}
+	}
+}
+
+// Run starts the continuous update cycle. Once ctx is cancelled it returns
+// after the propagation checks still running have stopped.
+func (c *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Update(ctx)
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-c.trigger:
+			log.Println("network change detected; checking IP")
+			c.invalidate()
+			continue
+		case <-ctx.Done():
+			log.Println("shutting down")
+			for _, s := range c.services {
+				s.Wait()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+	"github.com/epsilonrhorho/dns-updater/ipify"
+)
+
+func TestCoordinator_UpdateSharesLookup(t *testing.T) {
+	var mu sync.Mutex
+	lookups := 0
+	shared := ipify.NewCachedClient(&mockIPClient{
+		getIPFunc: func(ctx context.Context) (string, error) {
+			mu.Lock()
+			lookups++
+			mu.Unlock()
+			return "203.0.113.1", nil
+		},
+	}, time.Minute)
+
+	overrideLookups := 0
+	override := &mockIPClient{
+		getIPFunc: func(ctx context.Context) (string, error) {
+			overrideLookups++
+			return "203.0.113.2", nil
+		},
+	}
+
+	updated := make(map[string]string)
+	dnsProvider := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			mu.Lock()
+			updated[name] = ip
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	var services []*Service
+	for i := 0; i < 5; i++ {
+		config := Config{Zone: "example.com", RecordName: fmt.Sprintf("host%d.example.com", i)}
+		family := Family{RecordType: "A", IPClient: shared, Storage: &mockStorage{}}
+		services = append(services, New(dnsProvider, []Family{family}, config))
+	}
+	config := Config{Zone: "example.com", RecordName: "override.example.com"}
+	family := Family{RecordType: "A", IPClient: override, Storage: &mockStorage{}}
+	services = append(services, New(dnsProvider, []Family{family}, config))
+
+	NewCoordinator(services, time.Hour).Update(context.Background())
+
+	if lookups != 1 {
+		t.Errorf("expected 1 shared lookup, got %d", lookups)
+	}
+	if overrideLookups != 1 {
+		t.Errorf("expected 1 override lookup, got %d", overrideLookups)
+	}
+	if len(updated) != 6 {
+		t.Fatalf("expected 6 records updated, got %v", updated)
+	}
+	if updated["host0.example.com"] != "203.0.113.1" || updated["override.example.com"] != "203.0.113.2" {
+		t.Errorf("unexpected updates: %v", updated)
+	}
+}
+
+func TestCoordinator_RunTriggerInvalidatesCache(t *testing.T) {
+	lookups := make(chan struct{}, 10)
+	shared := ipify.NewCachedClient(&mockIPClient{
+		getIPFunc: func(ctx context.Context) (string, error) {
+			lookups <- struct{}{}
+			return "203.0.113.1", nil
+		},
+	}, time.Hour)
+
+	config := Config{Zone: "example.com", RecordName: "home.example.com"}
+	family := Family{RecordType: "A", IPClient: shared, Storage: &mockStorage{}}
+	coordinator := NewCoordinator([]*Service{New(&mockDNSProvider{}, []Family{family}, config)}, time.Hour)
+
+	trigger := make(chan struct{})
+	coordinator.SetTrigger(trigger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		coordinator.Run(ctx)
+		close(done)
+	}()
+
+	waitLookup := func() {
+		t.Helper()
+		select {
+		case <-lookups:
+		case <-time.After(time.Second):
+			t.Fatal("expected an IP lookup")
+		}
+	}
+
+	waitLookup() // initial cycle
+	trigger <- struct{}{}
+	waitLookup() // triggered cycle bypasses the cache
+
+	cancel()
+	<-done
+}
+
+func TestCoordinator_RunWaitsForVerifications(t *testing.T) {
+	started := make(chan struct{})
+	var stopped atomic.Bool
+	propagation := &mockPropagation{
+		waitFunc: func(ctx context.Context, zone, name, recordType, ip string) error {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			stopped.Store(true)
+			return ctx.Err()
+		},
+	}
+
+	config := Config{Zone: "example.com", RecordName: "home.example.com", Propagation: propagation}
+	family := Family{RecordType: "A", IPClient: &mockIPClient{}, Storage: &mockStorage{}}
+	coordinator := NewCoordinator([]*Service{New(&mockDNSProvider{}, []Family{family}, config)}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		coordinator.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	<-done
+	if !stopped.Load() {
+		t.Error("expected Run to wait for the propagation check")
+	}
+}
+
+func TestCoordinator_UpdateBatchesPerZone(t *testing.T) {
+	var mu sync.Mutex
+	batches := make(map[string][]dns.Record)
+	single := 0
+	provider := &mockBatchProvider{
+		mockDNSProvider: mockDNSProvider{
+			updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+				mu.Lock()
+				single++
+				mu.Unlock()
+				return nil
+			},
+		},
+		updateRecordsFunc: func(ctx context.Context, zone string, records []dns.Record) []error {
+			mu.Lock()
+			batches[zone] = append(batches[zone], records...)
+			mu.Unlock()
+			errs := make([]error, len(records))
+			for i, r := range records {
+				if r.Name == "bad.example.com" {
+					errs[i] = dns.Permanent(errDNS)
+				}
+			}
+			return errs
+		},
+	}
+
+	stored := make(map[string]string)
+	newService := func(name, zone string, p dns.Provider) *Service {
+		storage := &mockStorage{
+			writeIPFunc: func(ip string) error {
+				mu.Lock()
+				stored[name] = ip
+				mu.Unlock()
+				return nil
+			},
+		}
+		config := Config{Zone: zone, RecordName: name, TTL: time.Minute}
+		family := Family{RecordType: "A", IPClient: &mockIPClient{}, Storage: storage}
+		return New(p, []Family{family}, config)
+	}
+
+	otherAccount := &mockBatchProvider{}
+	services := []*Service{
+		newService("a.example.com", "example.com", provider),
+		newService("b.example.com", "example.com", provider),
+		newService("bad.example.com", "example.com", provider),
+		newService("c.example.org", "example.org", provider),
+		newService("d.example.com", "example.com", otherAccount),
+	}
+
+	NewCoordinator(services, time.Hour).Update(context.Background())
+
+	if len(batches["example.com"]) != 3 {
+		t.Errorf("expected one batch of 3 records for example.com, got %v", batches["example.com"])
+	}
+	if _, ok := batches["example.org"]; ok || single != 1 {
+		t.Errorf("expected the lone example.org record to be updated on its own, got %d single updates", single)
+	}
+	for _, name := range []string{"a.example.com", "b.example.com", "c.example.org", "d.example.com"} {
+		if _, ok := stored[name]; !ok {
+			t.Errorf("expected %s to be stored", name)
+		}
+	}
+	if _, ok := stored["bad.example.com"]; ok {
+		t.Error("failed record should not have been stored")
+	}
+}
+
+func TestCoordinator_UpdateBatchRetriesFailedRecords(t *testing.T) {
+	calls := [][]string{}
+	provider := &mockBatchProvider{
+		updateRecordsFunc: func(ctx context.Context, zone string, records []dns.Record) []error {
+			var names []string
+			errs := make([]error, len(records))
+			for i, r := range records {
+				names = append(names, r.Name)
+				if r.Name == "flaky.example.com" && len(calls) == 0 {
+					errs[i] = errDNS
+				}
+			}
+			calls = append(calls, names)
+			return errs
+		},
+	}
+
+	var services []*Service
+	for _, name := range []string{"stable.example.com", "flaky.example.com"} {
+		config := Config{Zone: "example.com", RecordName: name, Retry: RetryConfig{MaxAttempts: 2}}
+		family := Family{RecordType: "A", IPClient: &mockIPClient{}, Storage: &mockStorage{}}
+		s := New(provider, []Family{family}, config)
+		s.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+		services = append(services, s)
+	}
+
+	NewCoordinator(services, time.Hour).Update(context.Background())
+
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 batches, got %v", calls)
+	}
+	if len(calls[0]) != 2 || len(calls[1]) != 1 || calls[1][0] != "flaky.example.com" {
+		t.Errorf("expected only the failed record to be retried, got %v", calls)
+	}
+}
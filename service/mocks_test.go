@@ -4,19 +4,41 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
 )
 
 type mockDNSProvider struct {
-	updateARecordFunc func(ctx context.Context, zone, name, ip string, ttl time.Duration) error
+	updateRecordFunc func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error
+	getRecordsFunc   func(ctx context.Context, zone, name, recordType string) ([]string, error)
 }
 
-func (m *mockDNSProvider) UpdateARecord(ctx context.Context, zone, name, ip string, ttl time.Duration) error {
-	if m.updateARecordFunc != nil {
-		return m.updateARecordFunc(ctx, zone, name, ip, ttl)
+func (m *mockDNSProvider) UpdateRecord(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+	if m.updateRecordFunc != nil {
+		return m.updateRecordFunc(ctx, zone, name, recordType, ip, ttl)
 	}
 	return nil
 }
 
+func (m *mockDNSProvider) GetRecords(ctx context.Context, zone, name, recordType string) ([]string, error) {
+	if m.getRecordsFunc != nil {
+		return m.getRecordsFunc(ctx, zone, name, recordType)
+	}
+	return nil, nil
+}
+
+type mockBatchProvider struct {
+	mockDNSProvider
+	updateRecordsFunc func(ctx context.Context, zone string, records []dns.Record) []error
+}
+
+func (m *mockBatchProvider) UpdateRecords(ctx context.Context, zone string, records []dns.Record) []error {
+	if m.updateRecordsFunc != nil {
+		return m.updateRecordsFunc(ctx, zone, records)
+	}
+	return make([]error, len(records))
+}
+
 type mockIPClient struct {
 	getIPFunc func(ctx context.Context) (string, error)
 }
@@ -29,8 +51,10 @@ func (m *mockIPClient) GetIP(ctx context.Context) (string, error) {
 }
 
 type mockStorage struct {
-	readLastIPFunc func() (string, error)
-	writeIPFunc    func(ip string) error
+	readLastIPFunc  func() (string, error)
+	writeIPFunc     func(ip string) error
+	writeStatusFunc func(status string) error
+	rejection       string
 }
 
 func (m *mockStorage) ReadLastIP() (string, error) {
@@ -47,8 +71,42 @@ func (m *mockStorage) WriteIP(ip string) error {
 	return nil
 }
 
+func (m *mockStorage) WriteStatus(status string) error {
+	if m.writeStatusFunc != nil {
+		return m.writeStatusFunc(status)
+	}
+	return nil
+}
+
+func (m *mockStorage) WriteRejection(reason string) error {
+	m.rejection = reason
+	return nil
+}
+
+type mockPropagation struct {
+	waitFunc func(ctx context.Context, zone, name, recordType, ip string) error
+}
+
+func (m *mockPropagation) Wait(ctx context.Context, zone, name, recordType, ip string) error {
+	if m.waitFunc != nil {
+		return m.waitFunc(ctx, zone, name, recordType, ip)
+	}
+	return nil
+}
+
+type mockZoneFinder struct {
+	findZoneFunc func(ctx context.Context, name string) (string, error)
+}
+
+func (m *mockZoneFinder) FindZone(ctx context.Context, name string) (string, error) {
+	if m.findZoneFunc != nil {
+		return m.findZoneFunc(ctx, name)
+	}
+	return "example.com", nil
+}
+
 var (
 	errDNS     = errors.New("dns provider error")
 	errIP      = errors.New("ip client error")
 	errStorage = errors.New("storage error")
-)
\ No newline at end of file
+)
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+)
+
+// RetryConfig controls how failed lookups and updates are retried within a
+// single update cycle.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles after
+	// every further attempt, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction, between 0 and 1, by which each delay is
+	// randomly shortened so that many updaters don't retry in lockstep.
+	Jitter float64
+}
+
+// backoff returns the delay before the given retry, counting from 1.
+func (c RetryConfig) backoff(retry int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < retry && (c.MaxBackoff == 0 || delay < c.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	if c.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * c.Jitter * rand.Float64())
+	}
+	return delay
+}
+
+// IsRetryable reports whether an operation that failed with err may succeed
+// if tried again. Timeouts, server errors, throttling and unrecognized
+// errors are retryable; rejected addresses, permanent provider errors and
+// other HTTP client errors are not. A joined error is retryable if any of
+// its errors is.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if IsRetryable(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if dns.IsThrottled(err) {
+		return true
+	}
+	var rejected *RejectedError
+	if errors.As(err, &rejected) || dns.IsPermanent(err) {
+		return false
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		code := statusErr.HTTPStatusCode()
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	return true
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// retryable, or the configured number of attempts is exhausted. If the
+// provider asks to wait before retrying, the service holds off instead.
+func (s *Service) retry(ctx context.Context, op string, fn func() error) error {
+	cfg := s.config.Retry
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !IsRetryable(err) {
+			if cfg.MaxAttempts > 1 {
+				log.Printf("%s failed permanently; not retrying: %v", op, err)
+			}
+			return err
+		}
+		if wait := dns.RetryAfter(err); wait > 0 {
+			s.holdOff(op, wait, err)
+			return err
+		}
+		if attempt >= cfg.MaxAttempts {
+			return err
+		}
+
+		delay := cfg.backoff(attempt)
+		log.Printf("%s failed (attempt %d/%d): %v; retrying in %s", op, attempt, cfg.MaxAttempts, err, delay)
+		if s.sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// holdOff keeps the service from updating its record for wait, as the
+// provider asked after failing op with err.
+func (s *Service) holdOff(op string, wait time.Duration, err error) {
+	s.holdUntil = s.now().Add(wait)
+	log.Printf("%s failed: %v; provider asks to wait %s before retrying", op, err, wait)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
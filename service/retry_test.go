@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+	"github.com/epsilonrhorho/dns-updater/ipify"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "unknown error", err: errDNS, expected: true},
+		{name: "timeout", err: fmt.Errorf("lookup: %w", timeoutError{}), expected: true},
+		{name: "canceled", err: context.Canceled, expected: false},
+		{name: "server error", err: &ipify.StatusError{Code: http.StatusBadGateway}, expected: true},
+		{name: "throttled", err: &ipify.StatusError{Code: http.StatusTooManyRequests}, expected: true},
+		{name: "not found", err: &ipify.StatusError{Code: http.StatusNotFound}, expected: false},
+		{name: "permanent provider error", err: dns.Permanent(errDNS), expected: false},
+		{name: "rejected address", err: &RejectedError{IP: "10.0.0.1", Reason: "private"}, expected: false},
+		{
+			name:     "joined with one retryable",
+			err:      errors.Join(&ipify.StatusError{Code: http.StatusNotFound}, timeoutError{}),
+			expected: true,
+		},
+		{
+			name:     "joined permanent",
+			err:      errors.Join(&ipify.StatusError{Code: http.StatusForbidden}, dns.Permanent(errDNS)),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryConfig_backoff(t *testing.T) {
+	cfg := RetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := cfg.backoff(i + 1); got != want {
+			t.Errorf("retry %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+
+	cfg.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := cfg.backoff(2); got < time.Second || got > 2*time.Second {
+			t.Fatalf("jittered backoff %s outside [1s, 2s]", got)
+		}
+	}
+}
+
+func TestService_UpdateRetries(t *testing.T) {
+	tests := []struct {
+		name            string
+		dnsErrors       []error
+		expectError     bool
+		expectDNSCalls  int
+		expectedSleeps  []time.Duration
+		expectStoredIP  bool
+		expectPermanent bool
+	}{
+		{
+			name:           "succeeds after transient failures",
+			dnsErrors:      []error{errDNS, errDNS},
+			expectDNSCalls: 3,
+			expectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+			expectStoredIP: true,
+		},
+		{
+			name:           "gives up after max attempts",
+			dnsErrors:      []error{errDNS, errDNS, errDNS, errDNS},
+			expectError:    true,
+			expectDNSCalls: 3,
+			expectedSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:            "permanent error is not retried",
+			dnsErrors:       []error{dns.Permanent(errDNS)},
+			expectError:     true,
+			expectDNSCalls:  1,
+			expectPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsCalls := 0
+			dnsProvider := &mockDNSProvider{
+				updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+					dnsCalls++
+					if dnsCalls <= len(tt.dnsErrors) {
+						return tt.dnsErrors[dnsCalls-1]
+					}
+					return nil
+				},
+			}
+			stored := false
+			storage := &mockStorage{
+				readLastIPFunc: func() (string, error) { return "192.168.1.1", nil },
+				writeIPFunc:    func(ip string) error { stored = true; return nil },
+			}
+			ipClient := &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) { return "192.168.1.2", nil }}
+
+			config := Config{
+				Zone:       "example.com",
+				RecordName: "test.example.com",
+				TTL:        5 * time.Minute,
+				Retry:      RetryConfig{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute},
+			}
+			service := New(dnsProvider, []Family{{RecordType: "A", IPClient: ipClient, Storage: storage}}, config)
+			var sleeps []time.Duration
+			service.sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			err := service.Update(context.Background())
+
+			if tt.expectError && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectPermanent && !dns.IsPermanent(err) {
+				t.Errorf("expected permanent error, got %v", err)
+			}
+			if dnsCalls != tt.expectDNSCalls {
+				t.Errorf("expected %d DNS calls, got %d", tt.expectDNSCalls, dnsCalls)
+			}
+			if len(sleeps) != len(tt.expectedSleeps) {
+				t.Fatalf("expected sleeps %v, got %v", tt.expectedSleeps, sleeps)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.expectedSleeps[i] {
+					t.Errorf("expected sleeps %v, got %v", tt.expectedSleeps, sleeps)
+				}
+			}
+			if stored != tt.expectStoredIP {
+				t.Errorf("expected stored=%v, got %v", tt.expectStoredIP, stored)
+			}
+		})
+	}
+}
+
+func TestService_UpdateRetriesIPLookup(t *testing.T) {
+	calls := 0
+	ipClient := &mockIPClient{
+		getIPFunc: func(ctx context.Context) (string, error) {
+			calls++
+			if calls == 1 {
+				return "", &ipify.StatusError{Code: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+			}
+			return "192.168.1.2", nil
+		},
+	}
+	config := Config{Retry: RetryConfig{MaxAttempts: 2}}
+	service := New(&mockDNSProvider{}, []Family{{RecordType: "A", IPClient: ipClient, Storage: &mockStorage{}}}, config)
+	service.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 lookups, got %d", calls)
+	}
+}
+
+func TestService_UpdateRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	dnsProvider := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			calls++
+			return errDNS
+		},
+	}
+	config := Config{Retry: RetryConfig{MaxAttempts: 5, InitialBackoff: time.Hour}}
+	service := New(dnsProvider, []Family{{RecordType: "A", IPClient: &mockIPClient{}, Storage: &mockStorage{}}}, config)
+	service.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleepContext(ctx, d)
+	}
+
+	if err := service.Update(ctx); !errors.Is(err, errDNS) {
+		t.Fatalf("expected last DNS error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 DNS call, got %d", calls)
+	}
+}
+
+func TestService_UpdateHoldsOffWhenAsked(t *testing.T) {
+	calls := 0
+	dnsProvider := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			calls++
+			if calls == 1 {
+				return dns.ThrottledFor(errDNS, 30*time.Minute)
+			}
+			return nil
+		},
+	}
+	config := Config{Retry: RetryConfig{MaxAttempts: 3}}
+	service := New(dnsProvider, []Family{{RecordType: "A", IPClient: &mockIPClient{}, Storage: &mockStorage{}}}, config)
+	service.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	if err := service.Update(context.Background()); !errors.Is(err, errDNS) {
+		t.Fatalf("expected DNS error, got %v", err)
+	}
+	now = now.Add(29 * time.Minute)
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no retry within 30 minutes, got %d calls", calls)
+	}
+
+	now = now.Add(time.Minute)
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected an update once the wait is over, got %d calls", calls)
+	}
+}
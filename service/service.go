@@ -2,56 +2,106 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/epsilonrhorho/dns-updater/dns"
 	"github.com/epsilonrhorho/dns-updater/ipify"
+	"github.com/epsilonrhorho/dns-updater/propagation"
 	"github.com/epsilonrhorho/dns-updater/storage"
 )
 
 // Config holds the configuration for the DNS updater service.
 type Config struct {
-	Zone       string
+	Zone string
+	// ZoneFinder, if set, is asked for the zone of the record at the start
+	// of each cycle while Zone is empty. A cycle in which the lookup fails
+	// publishes nothing, so the zone is found once the network is up.
+	ZoneFinder ZoneFinder
 	RecordName string
 	TTL        time.Duration
+	// Validator rejects addresses that must not be published. If nil,
+	// every address returned by the IP client is accepted.
+	Validator *Validator
+	// Retry controls retries of failed lookups and updates within a cycle.
+	// The zero value disables retries.
+	Retry RetryConfig
+	// ReconcileInterval is how often the records are compared against the
+	// live DNS records instead of the stored address, so that records
+	// edited elsewhere or a lost state file are noticed. Zero disables
+	// reconciliation.
+	ReconcileInterval time.Duration
+	// Propagation, if set, is waited on in the background after each update
+	// to confirm that the authoritative name servers serve the new address.
+	Propagation propagation.Interface
+}
+
+// ZoneFinder finds the zone a record name belongs to. It is implemented by
+// propagation.Verifier.
+type ZoneFinder interface {
+	FindZone(ctx context.Context, name string) (string, error)
+}
+
+// Family binds a DNS record type to the client that discovers its address
+// and the storage that remembers the last address published for it.
+type Family struct {
+	RecordType string // dns.RecordTypeA or dns.RecordTypeAAAA
+	IPClient   ipify.ClientInterface
+	Storage    storage.Interface
 }
 
 // Service handles DNS updates with dependency injection.
 type Service struct {
 	dnsProvider dns.Provider
-	ipClient    ipify.ClientInterface
-	storage     storage.Interface
+	families    []Family
 	config      Config
-	interval    time.Duration
+	sleep       func(ctx context.Context, d time.Duration) error
+	now         func() time.Time
+
+	lastReconcile time.Time
+	// holdUntil is when the provider allows the next update, if it asked
+	// to wait after a failure.
+	holdUntil time.Time
+
+	// verifyMu guards verifying and the status writes of the propagation
+	// checks, so a check superseded by a newer update cannot overwrite the
+	// status of that update.
+	verifyMu      sync.Mutex
+	verifying     map[string]context.CancelFunc // by record type
+	verifications sync.WaitGroup
 }
 
-// New creates a new Service instance.
+// New creates a new Service instance that keeps one record of each of the
+// given families up to date.
 func New(
 	dnsProvider dns.Provider,
-	ipClient ipify.ClientInterface,
-	storage storage.Interface,
+	families []Family,
 	config Config,
-	interval time.Duration,
 ) *Service {
 	return &Service{
 		dnsProvider: dnsProvider,
-		ipClient:    ipClient,
-		storage:     storage,
+		families:    families,
 		config:      config,
-		interval:    interval,
+		sleep:       sleepContext,
+		now:         time.Now,
+		verifying:   make(map[string]context.CancelFunc),
 	}
 }
 
-// getCurrentIP fetches the current public IP address.
-func (s *Service) getCurrentIP(ctx context.Context) (string, error) {
-	return s.ipClient.GetIP(ctx)
+// getCurrentIP fetches the current public IP address for the family.
+func (s *Service) getCurrentIP(ctx context.Context, f Family) (string, error) {
+	return f.IPClient.GetIP(ctx)
 }
 
 // hasIPChanged checks if the current IP differs from the stored IP.
-func (s *Service) hasIPChanged(currentIP string) (bool, error) {
-	lastIP, err := s.storage.ReadLastIP()
+func (s *Service) hasIPChanged(f Family, currentIP string) (bool, error) {
+	lastIP, err := f.Storage.ReadLastIP()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return true, nil
@@ -61,62 +111,343 @@ func (s *Service) hasIPChanged(currentIP string) (bool, error) {
 	return lastIP != currentIP, nil
 }
 
-// updateDNSRecord updates the DNS A record with the new IP.
-func (s *Service) updateDNSRecord(ctx context.Context, ip string) error {
-	return s.dnsProvider.UpdateARecord(ctx, s.config.Zone, s.config.RecordName, ip, s.config.TTL)
+// hasDrifted checks if the live DNS records lack the current IP. Further
+// addresses of the name, e.g. of other hosts, are not drift: providers that
+// store each address as a record of its own leave them alone on update. If
+// the record is correct but the stored IP is not, the storage is brought up
+// to date.
+func (s *Service) hasDrifted(ctx context.Context, f Family, currentIP string) (bool, error) {
+	var published []string
+	err := s.retry(ctx, "DNS "+f.RecordType+" lookup", func() error {
+		var err error
+		published, err = s.dnsProvider.GetRecords(ctx, s.config.Zone, s.config.RecordName, f.RecordType)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	for _, addr := range published {
+		if net.ParseIP(addr).Equal(net.ParseIP(currentIP)) {
+			found = true
+		}
+	}
+	if !found {
+		log.Printf("drift detected: %s record %s is %v, expected %s", f.RecordType, s.config.RecordName, published, currentIP)
+		return true, nil
+	}
+
+	stale, err := s.hasIPChanged(f, currentIP)
+	if err != nil {
+		return false, err
+	}
+	if stale {
+		if err := s.storeIP(f, currentIP); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// updateDNSRecord updates the DNS record of the family with the new IP.
+func (s *Service) updateDNSRecord(ctx context.Context, f Family, ip string) error {
+	return s.dnsProvider.UpdateRecord(ctx, s.config.Zone, s.config.RecordName, f.RecordType, ip, s.config.TTL)
 }
 
 // storeIP persists the IP address to storage.
-func (s *Service) storeIP(ip string) error {
-	return s.storage.WriteIP(ip)
+func (s *Service) storeIP(f Family, ip string) error {
+	return f.Storage.WriteIP(ip)
 }
 
-// Update performs a single DNS update check and update if necessary.
-func (s *Service) Update(ctx context.Context) error {
-	ip, err := s.getCurrentIP(ctx)
+// statusWriter is implemented by storages that record the propagation
+// state of the published address, such as storage.FileStorage.
+type statusWriter interface {
+	WriteStatus(status string) error
+}
+
+// rejectionWriter is implemented by storages that record why a discovered
+// address was not published, such as storage.FileStorage.
+type rejectionWriter interface {
+	WriteRejection(reason string) error
+}
+
+// recordRejection persists the reason err rejected the discovered address
+// of the family, or clears it if the address was accepted.
+func (s *Service) recordRejection(f Family, err error) {
+	w, ok := f.Storage.(rejectionWriter)
+	if !ok {
+		return
+	}
+	var reason string
+	var rejected *RejectedError
+	if errors.As(err, &rejected) {
+		reason = rejected.Error()
+	}
+	if err := w.WriteRejection(reason); err != nil {
+		log.Printf("failed to record rejection of %s record %s: %v", f.RecordType, s.config.RecordName, err)
+	}
+}
+
+// Wait blocks until the propagation checks started by the service have
+// finished. Cancelling the context of the updates stops them early.
+func (s *Service) Wait() {
+	s.verifications.Wait()
+}
+
+// startVerification marks the address just published for the family as
+// pending and checks its propagation in the background, so the update cycle
+// does not wait for the name servers. A check still running for an earlier
+// address of the family is cancelled.
+func (s *Service) startVerification(ctx context.Context, f Family, ip string) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.verifyMu.Lock()
+	if previous := s.verifying[f.RecordType]; previous != nil {
+		previous()
+	}
+	s.verifying[f.RecordType] = cancel
+	s.writeStatus(f, storage.StatusPending)
+	s.verifyMu.Unlock()
+
+	s.verifications.Add(1)
+	go func() {
+		defer s.verifications.Done()
+		defer cancel()
+		s.verifyPropagation(ctx, f, ip)
+	}()
+}
+
+// verifyPropagation waits for the published address to be served by the
+// zone's authoritative name servers and records the update as applied once
+// it is. An update still pending when the check gives up is not an error:
+// the provider accepted it and the name servers will catch up.
+func (s *Service) verifyPropagation(ctx context.Context, f Family, ip string) {
+	err := s.config.Propagation.Wait(ctx, s.config.Zone, s.config.RecordName, f.RecordType, ip)
+
+	s.verifyMu.Lock()
+	defer s.verifyMu.Unlock()
+	if ctx.Err() != nil {
+		// Superseded by a newer update, or shutting down.
+		return
+	}
 	if err != nil {
+		log.Printf("DNS %s record %s pending propagation: %v", f.RecordType, s.config.RecordName, err)
+		return
+	}
+	log.Printf("DNS %s record %s applied on all authoritative name servers", f.RecordType, s.config.RecordName)
+	s.writeStatus(f, storage.StatusApplied)
+}
+
+// writeStatus records the propagation status of the family's address if the
+// storage supports it.
+func (s *Service) writeStatus(f Family, status string) {
+	if w, ok := f.Storage.(statusWriter); ok {
+		if err := w.WriteStatus(status); err != nil {
+			log.Printf("failed to record propagation status of %s record %s: %v", f.RecordType, s.config.RecordName, err)
+		}
+	}
+}
+
+// change is an address that has to be published for a family of a service.
+type change struct {
+	service *Service
+	family  Family
+	ip      string
+}
+
+// checkFamily looks up the current address of a family and reports whether
+// it differs from the address last published, or from the live DNS record
+// if reconcile is set.
+func (s *Service) checkFamily(ctx context.Context, f Family, reconcile bool) (string, bool, error) {
+	var ip string
+	err := s.retry(ctx, f.RecordType+" IP lookup", func() error {
+		var err error
+		ip, err = s.getCurrentIP(ctx, f)
 		return err
+	})
+	if err != nil {
+		return "", false, err
 	}
-	log.Println("Public IP:", ip)
+	log.Printf("Public IP (%s): %s", f.RecordType, ip)
 
-	changed, err := s.hasIPChanged(ip)
+	if s.config.Validator != nil {
+		err := s.config.Validator.Validate(f.RecordType, ip)
+		s.recordRejection(f, err)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	var changed bool
+	if reconcile {
+		changed, err = s.hasDrifted(ctx, f, ip)
+	} else {
+		changed, err = s.hasIPChanged(f, ip)
+	}
 	if err != nil {
-		return err
+		return "", false, err
 	}
 
 	if !changed {
-		log.Println("IP unchanged; skipping DNS update")
-		return nil
+		log.Printf("IP unchanged; skipping DNS %s update", f.RecordType)
 	}
+	return ip, changed, nil
+}
 
-	if err := s.updateDNSRecord(ctx, ip); err != nil {
-		return err
+// plan checks every family and returns the changes that have to be
+// published, along with the errors of the families that could not be
+// checked.
+func (s *Service) plan(ctx context.Context) ([]change, []error) {
+	if s.now().Before(s.holdUntil) {
+		log.Printf("%s: not updating before %s, as the provider asked", s.config.RecordName, s.holdUntil.Format(time.RFC3339))
+		return nil, nil
+	}
+	if err := s.resolveZone(ctx); err != nil {
+		return nil, []error{err}
 	}
 
-	if err := s.storeIP(ip); err != nil {
-		return err
+	now := s.now()
+	reconcile := s.config.ReconcileInterval > 0 && now.Sub(s.lastReconcile) >= s.config.ReconcileInterval
+
+	var changes []change
+	var errs []error
+	for _, f := range s.families {
+		ip, changed, err := s.checkFamily(ctx, f, reconcile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s record: %w", f.RecordType, err))
+			continue
+		}
+		if changed {
+			changes = append(changes, change{service: s, family: f, ip: ip})
+		}
 	}
 
-	log.Println("DNS A record updated")
+	// A failed reconciliation is tried again in the next cycle.
+	if reconcile && len(errs) == 0 {
+		s.lastReconcile = now
+	}
+	return changes, errs
+}
+
+// resolveZone finds the zone of the record unless it is known already.
+func (s *Service) resolveZone(ctx context.Context) error {
+	if s.config.Zone != "" || s.config.ZoneFinder == nil {
+		return nil
+	}
+	// A wildcard record lives in the zone of the name it covers.
+	name := strings.TrimPrefix(s.config.RecordName, "*.")
+	var zone string
+	err := s.retry(ctx, "zone lookup", func() error {
+		lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		var err error
+		zone, err = s.config.ZoneFinder.FindZone(lookupCtx, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("zone lookup: %w", err)
+	}
+	log.Printf("%s is in zone %s", s.config.RecordName, zone)
+	s.config.Zone = zone
 	return nil
 }
 
-// Run starts the continuous DNS update service.
-func (s *Service) Run(ctx context.Context) {
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
+// applyChanges publishes changes that share a DNS provider and zone and
+// stores the addresses that were published. If the provider supports it,
+// all changes are sent in a single batch. Propagation of the published
+// addresses is verified in the background. It returns one error per change.
+func applyChanges(ctx context.Context, changes []change) []error {
+	errs := make([]error, len(changes))
+	if len(changes) == 0 {
+		return errs
+	}
 
-	for {
-		if err := s.Update(ctx); err != nil {
-			log.Printf("update failed: %v", err)
+	s := changes[0].service
+	if batch, ok := s.dnsProvider.(dns.BatchProvider); ok && len(changes) > 1 {
+		s.updateBatch(ctx, batch, changes, errs)
+	} else {
+		for i, c := range changes {
+			errs[i] = c.service.retry(ctx, "DNS "+c.family.RecordType+" update", func() error {
+				return c.service.updateDNSRecord(ctx, c.family, c.ip)
+			})
 		}
+	}
 
-		select {
-		case <-ticker.C:
+	for i, c := range changes {
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("%s record: %w", c.family.RecordType, errs[i])
+			continue
+		}
+		if err := c.service.storeIP(c.family, c.ip); err != nil {
+			errs[i] = fmt.Errorf("%s record: %w", c.family.RecordType, err)
 			continue
-		case <-ctx.Done():
-			log.Println("shutting down")
+		}
+		log.Printf("DNS %s record %s updated", c.family.RecordType, c.service.config.RecordName)
+
+		if c.service.config.Propagation != nil {
+			c.service.startVerification(ctx, c.family, c.ip)
+		}
+	}
+	return errs
+}
+
+// updateBatch publishes changes with one request per attempt, retrying
+// only the records that failed with a retryable error.
+func (s *Service) updateBatch(ctx context.Context, batch dns.BatchProvider, changes []change, errs []error) {
+	cfg := s.config.Retry
+	pending := make([]int, len(changes))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for attempt := 1; ; attempt++ {
+		records := make([]dns.Record, len(pending))
+		for j, i := range pending {
+			c := changes[i]
+			records[j] = dns.Record{
+				Name: c.service.config.RecordName,
+				Type: c.family.RecordType,
+				IP:   c.ip,
+				TTL:  c.service.config.TTL,
+			}
+		}
+
+		log.Printf("updating %d records in zone %s in one batch", len(records), s.config.Zone)
+		results := batch.UpdateRecords(ctx, s.config.Zone, records)
+
+		var retry []int
+		for j, i := range pending {
+			errs[i] = results[j]
+			if wait := dns.RetryAfter(results[j]); wait > 0 {
+				changes[i].service.holdOff("DNS "+changes[i].family.RecordType+" update", wait, results[j])
+			} else if IsRetryable(results[j]) {
+				retry = append(retry, i)
+			}
+		}
+		if len(retry) == 0 || attempt >= cfg.MaxAttempts {
+			return
+		}
+
+		delay := cfg.backoff(attempt)
+		log.Printf("batch update of zone %s failed for %d records (attempt %d/%d); retrying in %s",
+			s.config.Zone, len(retry), attempt, cfg.MaxAttempts, delay)
+		if s.sleep(ctx, delay) != nil {
 			return
 		}
+		pending = retry
+	}
+}
+
+// Update performs a single DNS update check and update if necessary.
+// Each family is reconciled independently, so a failure to update one
+// record type does not prevent the others from being updated.
+func (s *Service) Update(ctx context.Context) error {
+	changes, errs := s.plan(ctx)
+	for _, err := range applyChanges(ctx, changes) {
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
-}
\ No newline at end of file
+	return errors.Join(errs...)
+}
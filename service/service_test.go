@@ -2,8 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+	"github.com/epsilonrhorho/dns-updater/storage"
 )
 
 func TestService_Update(t *testing.T) {
@@ -78,7 +86,7 @@ func TestService_Update(t *testing.T) {
 			var dnsProviderCalled, storageCalled bool
 
 			mockDNS := &mockDNSProvider{
-				updateARecordFunc: func(ctx context.Context, zone, name, ip string, ttl time.Duration) error {
+				updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
 					dnsProviderCalled = true
 					return tt.dnsError
 				},
@@ -106,7 +114,8 @@ func TestService_Update(t *testing.T) {
 				TTL:        60 * time.Second,
 			}
 
-			service := New(mockDNS, mockIP, mockStore, config, time.Minute)
+			family := Family{RecordType: "A", IPClient: mockIP, Storage: mockStore}
+			service := New(mockDNS, []Family{family}, config)
 			err := service.Update(context.Background())
 
 			if tt.expectError && err == nil {
@@ -155,8 +164,8 @@ func TestService_getCurrentIP(t *testing.T) {
 				},
 			}
 
-			service := &Service{ipClient: mockIP}
-			ip, err := service.getCurrentIP(context.Background())
+			service := &Service{}
+			ip, err := service.getCurrentIP(context.Background(), Family{IPClient: mockIP})
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -214,8 +223,8 @@ func TestService_hasIPChanged(t *testing.T) {
 				},
 			}
 
-			service := &Service{storage: mockStore}
-			changed, err := service.hasIPChanged(tt.currentIP)
+			service := &Service{}
+			changed, err := service.hasIPChanged(Family{Storage: mockStore}, tt.currentIP)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -233,17 +242,26 @@ func TestService_hasIPChanged(t *testing.T) {
 func TestService_updateDNSRecord(t *testing.T) {
 	tests := []struct {
 		name        string
+		recordType  string
 		ip          string
 		dnsError    error
 		expectError bool
 	}{
 		{
 			name:        "successful DNS update",
+			recordType:  "A",
 			ip:          "192.168.1.1",
 			expectError: false,
 		},
+		{
+			name:        "successful AAAA update",
+			recordType:  "AAAA",
+			ip:          "2001:db8::1",
+			expectError: false,
+		},
 		{
 			name:        "DNS update error",
+			recordType:  "A",
 			ip:          "192.168.1.1",
 			dnsError:    errDNS,
 			expectError: true,
@@ -254,8 +272,8 @@ func TestService_updateDNSRecord(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			var capturedParams []interface{}
 			mockDNS := &mockDNSProvider{
-				updateARecordFunc: func(ctx context.Context, zone, name, ip string, ttl time.Duration) error {
-					capturedParams = []interface{}{zone, name, ip, ttl}
+				updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+					capturedParams = []interface{}{zone, name, recordType, ip, ttl}
 					return tt.dnsError
 				},
 			}
@@ -271,7 +289,7 @@ func TestService_updateDNSRecord(t *testing.T) {
 				config:      config,
 			}
 
-			err := service.updateDNSRecord(context.Background(), tt.ip)
+			err := service.updateDNSRecord(context.Background(), Family{RecordType: tt.recordType}, tt.ip)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -280,18 +298,21 @@ func TestService_updateDNSRecord(t *testing.T) {
 				t.Errorf("unexpected error: %v", err)
 			}
 
-			if !tt.expectError && len(capturedParams) == 4 {
+			if !tt.expectError && len(capturedParams) == 5 {
 				if capturedParams[0] != config.Zone {
 					t.Errorf("expected zone %q, got %q", config.Zone, capturedParams[0])
 				}
 				if capturedParams[1] != config.RecordName {
 					t.Errorf("expected record name %q, got %q", config.RecordName, capturedParams[1])
 				}
-				if capturedParams[2] != tt.ip {
-					t.Errorf("expected IP %q, got %q", tt.ip, capturedParams[2])
+				if capturedParams[2] != tt.recordType {
+					t.Errorf("expected record type %q, got %q", tt.recordType, capturedParams[2])
 				}
-				if capturedParams[3] != config.TTL {
-					t.Errorf("expected TTL %v, got %v", config.TTL, capturedParams[3])
+				if capturedParams[3] != tt.ip {
+					t.Errorf("expected IP %q, got %q", tt.ip, capturedParams[3])
+				}
+				if capturedParams[4] != config.TTL {
+					t.Errorf("expected TTL %v, got %v", config.TTL, capturedParams[4])
 				}
 			}
 		})
@@ -328,8 +349,8 @@ func TestService_storeIP(t *testing.T) {
 				},
 			}
 
-			service := &Service{storage: mockStore}
-			err := service.storeIP(tt.ip)
+			service := &Service{}
+			err := service.storeIP(Family{Storage: mockStore}, tt.ip)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error but got none")
@@ -349,9 +370,10 @@ func TestNew(t *testing.T) {
 	mockIP := &mockIPClient{}
 	mockStore := &mockStorage{}
 	config := Config{Zone: "example.com", RecordName: "test", TTL: 60 * time.Second}
-	interval := time.Minute
 
-	service := New(mockDNS, mockIP, mockStore, config, interval)
+	families := []Family{{RecordType: "A", IPClient: mockIP, Storage: mockStore}}
+
+	service := New(mockDNS, families, config)
 
 	if service == nil {
 		t.Fatal("expected non-nil service")
@@ -359,16 +381,357 @@ func TestNew(t *testing.T) {
 	if service.dnsProvider != mockDNS {
 		t.Error("DNS provider not properly set")
 	}
-	if service.ipClient != mockIP {
-		t.Error("IP client not properly set")
-	}
-	if service.storage != mockStore {
-		t.Error("storage not properly set")
+	if len(service.families) != 1 || service.families[0] != families[0] {
+		t.Error("families not properly set")
 	}
 	if service.config != config {
 		t.Error("config not properly set")
 	}
-	if service.interval != interval {
-		t.Error("interval not properly set")
+}
+
+func TestService_UpdateFamiliesIndependent(t *testing.T) {
+	updated := map[string]string{}
+	mockDNS := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			updated[recordType] = ip
+			return nil
+		},
+	}
+
+	v4 := Family{
+		RecordType: "A",
+		IPClient: &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) {
+			return "203.0.113.1", nil
+		}},
+		Storage: &mockStorage{},
+	}
+	v6 := Family{
+		RecordType: "AAAA",
+		IPClient: &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) {
+			return "", errIP
+		}},
+		Storage: &mockStorage{},
+	}
+
+	config := Config{Zone: "example.com", RecordName: "home", TTL: 60 * time.Second}
+	service := New(mockDNS, []Family{v6, v4}, config)
+
+	err := service.Update(context.Background())
+	if err == nil {
+		t.Fatal("expected error from IPv6 family")
+	}
+	if !errors.Is(err, errIP) {
+		t.Errorf("expected IP client error, got %v", err)
+	}
+	if updated["A"] != "203.0.113.1" {
+		t.Errorf("expected A record to be updated despite IPv6 failure, got %v", updated)
+	}
+	if _, ok := updated["AAAA"]; ok {
+		t.Error("AAAA record should not have been updated")
+	}
+}
+
+func TestService_UpdateFindsZone(t *testing.T) {
+	var zones []string
+	mockDNS := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			zones = append(zones, zone)
+			return nil
+		},
+	}
+
+	var lookups []string
+	finder := &mockZoneFinder{
+		findZoneFunc: func(ctx context.Context, name string) (string, error) {
+			lookups = append(lookups, name)
+			if len(lookups) == 1 {
+				return "", errDNS
+			}
+			return "lab.example.com", nil
+		},
+	}
+
+	ip := "203.0.113.1"
+	family := Family{
+		RecordType: "A",
+		IPClient: &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) {
+			return ip, nil
+		}},
+		Storage: &mockStorage{},
+	}
+	config := Config{ZoneFinder: finder, RecordName: "*.home.lab.example.com", TTL: time.Minute}
+	service := New(mockDNS, []Family{family}, config)
+
+	if err := service.Update(context.Background()); !errors.Is(err, errDNS) {
+		t.Errorf("expected zone lookup error, got %v", err)
+	}
+	if len(zones) != 0 {
+		t.Errorf("expected no update without a zone, got %v", zones)
+	}
+
+	for _, ip = range []string{"203.0.113.1", "203.0.113.2"} {
+		if err := service.Update(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(lookups, []string{"home.lab.example.com", "home.lab.example.com"}) {
+		t.Errorf("expected the zone to be looked up until found, got %v", lookups)
+	}
+	if !reflect.DeepEqual(zones, []string{"lab.example.com", "lab.example.com"}) {
+		t.Errorf("expected updates in the zone found, got %v", zones)
+	}
+}
+
+func TestService_UpdateReconcile(t *testing.T) {
+	tests := []struct {
+		name            string
+		published       []string
+		lastIP          string
+		expectDNSCalled bool
+		expectStoredIP  string
+	}{
+		{
+			name:            "record edited elsewhere",
+			published:       []string{"198.51.100.7"},
+			lastIP:          "203.0.113.1",
+			expectDNSCalled: true,
+			expectStoredIP:  "203.0.113.1",
+		},
+		{
+			name:            "record missing",
+			lastIP:          "203.0.113.1",
+			expectDNSCalled: true,
+			expectStoredIP:  "203.0.113.1",
+		},
+		{
+			name:           "lost state file",
+			published:      []string{"203.0.113.1"},
+			expectStoredIP: "203.0.113.1",
+		},
+		{
+			name:      "in sync",
+			published: []string{"203.0.113.1"},
+			lastIP:    "203.0.113.1",
+		},
+		{
+			name:      "in sync with further addresses",
+			published: []string{"198.51.100.7", "203.0.113.1"},
+			lastIP:    "203.0.113.1",
+		},
+		{
+			name:            "further addresses only",
+			published:       []string{"198.51.100.7", "198.51.100.8"},
+			lastIP:          "203.0.113.1",
+			expectDNSCalled: true,
+			expectStoredIP:  "203.0.113.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dnsCalled := false
+			dnsProvider := &mockDNSProvider{
+				updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+					dnsCalled = true
+					return nil
+				},
+				getRecordsFunc: func(ctx context.Context, zone, name, recordType string) ([]string, error) {
+					return tt.published, nil
+				},
+			}
+			storedIP := ""
+			storage := &mockStorage{
+				readLastIPFunc: func() (string, error) {
+					if tt.lastIP == "" {
+						return "", os.ErrNotExist
+					}
+					return tt.lastIP, nil
+				},
+				writeIPFunc: func(ip string) error {
+					storedIP = ip
+					return nil
+				},
+			}
+			ipClient := &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) { return "203.0.113.1", nil }}
+
+			config := Config{Zone: "example.com", RecordName: "home.example.com", ReconcileInterval: time.Hour}
+			service := New(dnsProvider, []Family{{RecordType: "A", IPClient: ipClient, Storage: storage}}, config)
+
+			if err := service.Update(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if dnsCalled != tt.expectDNSCalled {
+				t.Errorf("expected DNS called=%v, got %v", tt.expectDNSCalled, dnsCalled)
+			}
+			if storedIP != tt.expectStoredIP {
+				t.Errorf("expected stored IP %q, got %q", tt.expectStoredIP, storedIP)
+			}
+		})
+	}
+}
+
+func TestService_ReconcileInterval(t *testing.T) {
+	lookups := 0
+	dnsProvider := &mockDNSProvider{
+		getRecordsFunc: func(ctx context.Context, zone, name, recordType string) ([]string, error) {
+			lookups++
+			if lookups == 1 {
+				return nil, errDNS
+			}
+			return []string{"192.168.1.1"}, nil
+		},
+	}
+	storage := &mockStorage{readLastIPFunc: func() (string, error) { return "192.168.1.1", nil }}
+	config := Config{ReconcileInterval: time.Hour}
+	service := New(dnsProvider, []Family{{RecordType: "A", IPClient: &mockIPClient{}, Storage: storage}}, config)
+	now := time.Unix(0, 0)
+	service.now = func() time.Time { return now }
+
+	if err := service.Update(context.Background()); !errors.Is(err, errDNS) {
+		t.Fatalf("expected DNS lookup error, got %v", err)
+	}
+	// A failed reconciliation is retried in the next cycle.
+	now = now.Add(time.Minute)
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Within the interval, only the stored IP is consulted.
+	now = now.Add(30 * time.Minute)
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lookups != 2 {
+		t.Errorf("expected 2 DNS lookups, got %d", lookups)
+	}
+
+	now = now.Add(time.Hour)
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lookups != 3 {
+		t.Errorf("expected reconciliation after the interval, got %d lookups", lookups)
 	}
-}
\ No newline at end of file
+}
+
+func TestService_UpdatePropagation(t *testing.T) {
+	tests := []struct {
+		name     string
+		waitErr  error
+		expected []string
+	}{
+		{name: "applied", expected: []string{storage.StatusPending, storage.StatusApplied}},
+		{name: "pending", waitErr: errors.New("203.0.113.1 not yet served by ns1.example.net"), expected: []string{storage.StatusPending}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waited []string
+			var statuses []string
+			config := Config{
+				Zone:       "example.com",
+				RecordName: "home.example.com",
+				Propagation: &mockPropagation{
+					waitFunc: func(ctx context.Context, zone, name, recordType, ip string) error {
+						waited = append(waited, zone, name, recordType, ip)
+						return tt.waitErr
+					},
+				},
+			}
+			st := &mockStorage{
+				writeStatusFunc: func(s string) error {
+					statuses = append(statuses, s)
+					return nil
+				},
+			}
+			family := Family{RecordType: "A", IPClient: &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) {
+				return "203.0.113.1", nil
+			}}, Storage: st}
+			service := New(&mockDNSProvider{}, []Family{family}, config)
+
+			// Pending propagation does not fail the update.
+			if err := service.Update(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			service.Wait()
+			if strings.Join(waited, " ") != "example.com home.example.com A 203.0.113.1" {
+				t.Errorf("unexpected wait for %v", waited)
+			}
+			if !reflect.DeepEqual(statuses, tt.expected) {
+				t.Errorf("expected statuses %v, got %v", tt.expected, statuses)
+			}
+		})
+	}
+}
+
+func TestService_UpdatePropagationInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var statuses []string
+	config := Config{
+		Zone:       "example.com",
+		RecordName: "home.example.com",
+		Propagation: &mockPropagation{
+			waitFunc: func(ctx context.Context, zone, name, recordType, ip string) error {
+				if ip == "203.0.113.1" {
+					// Never served; superseded by the next update.
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				<-release
+				return nil
+			},
+		},
+	}
+	st := &mockStorage{
+		writeStatusFunc: func(s string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			statuses = append(statuses, s)
+			return nil
+		},
+	}
+	ip := "203.0.113.1"
+	family := Family{RecordType: "A", IPClient: &mockIPClient{getIPFunc: func(ctx context.Context) (string, error) {
+		return ip, nil
+	}}, Storage: st}
+	service := New(&mockDNSProvider{}, []Family{family}, config)
+
+	// Neither update waits for its propagation check.
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ip = "203.0.113.2"
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	service.Wait()
+
+	expected := []string{storage.StatusPending, storage.StatusPending, storage.StatusApplied}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected statuses %v, got %v", expected, statuses)
+	}
+}
+
+func TestService_UpdatePropagationSkippedOnFailure(t *testing.T) {
+	waited := false
+	config := Config{Propagation: &mockPropagation{
+		waitFunc: func(ctx context.Context, zone, name, recordType, ip string) error {
+			waited = true
+			return nil
+		},
+	}}
+	dnsProvider := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			return dns.Permanent(errDNS)
+		},
+	}
+	service := New(dnsProvider, []Family{{RecordType: "A", IPClient: &mockIPClient{}, Storage: &mockStorage{}}}, config)
+
+	if err := service.Update(context.Background()); !errors.Is(err, errDNS) {
+		t.Fatalf("expected DNS error, got %v", err)
+	}
+	if waited {
+		t.Error("expected no propagation check for a failed update")
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+)
+
+// reservedRange is an address block that is never a valid public address.
+type reservedRange struct {
+	network *net.IPNet
+	reason  string
+}
+
+// reservedRanges are rejected by default. Captive portals and misbehaving
+// lookup services tend to hand out addresses from these blocks.
+var reservedRanges = mustParseRanges(map[string]string{
+	"0.0.0.0/8":       "unspecified",
+	"10.0.0.0/8":      "private",
+	"100.64.0.0/10":   "CGNAT",
+	"127.0.0.0/8":     "loopback",
+	"169.254.0.0/16":  "link-local",
+	"172.16.0.0/12":   "private",
+	"192.0.2.0/24":    "documentation",
+	"192.168.0.0/16":  "private",
+	"198.18.0.0/15":   "benchmarking",
+	"198.51.100.0/24": "documentation",
+	"203.0.113.0/24":  "documentation",
+	"224.0.0.0/4":     "multicast",
+	"240.0.0.0/4":     "reserved",
+	"::/128":          "unspecified",
+	"::1/128":         "loopback",
+	"2001:db8::/32":   "documentation",
+	"fc00::/7":        "unique local",
+	"fe80::/10":       "link-local",
+	"ff00::/8":        "multicast",
+})
+
+func mustParseRanges(ranges map[string]string) []reservedRange {
+	var result []reservedRange
+	for cidr, reason := range ranges {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, reservedRange{network: network, reason: reason})
+	}
+	return result
+}
+
+// RejectedError is returned when a discovered address fails validation.
+type RejectedError struct {
+	IP     string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("refusing to publish %s: %s", e.IP, e.Reason)
+}
+
+// Validator decides whether a discovered address may be published.
+type Validator struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewValidator creates a new Validator from lists of CIDR blocks. Addresses
+// in deny are always rejected. If allow is non-empty, only addresses inside
+// it are accepted and the built-in reserved ranges are not consulted, so a
+// private address can be published deliberately. Otherwise private,
+// loopback, link-local, CGNAT, multicast and documentation addresses are
+// rejected.
+func NewValidator(allow, deny []string) (*Validator, error) {
+	v := &Validator{}
+	var err error
+	if v.allow, err = parseCIDRs(allow); err != nil {
+		return nil, err
+	}
+	if v.deny, err = parseCIDRs(deny); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// Validate checks that ip is a well-formed address of the family matching
+// recordType and allowed by the configured ranges.
+func (v *Validator) Validate(recordType, ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return &RejectedError{IP: ip, Reason: "not an IP address"}
+	}
+	if isIPv4 := parsed.To4() != nil; isIPv4 != (recordType == dns.RecordTypeA) {
+		return &RejectedError{IP: ip, Reason: fmt.Sprintf("wrong address family for %s record", recordType)}
+	}
+
+	for _, network := range v.deny {
+		if network.Contains(parsed) {
+			return &RejectedError{IP: ip, Reason: fmt.Sprintf("in denied range %s", network)}
+		}
+	}
+
+	if len(v.allow) > 0 {
+		for _, network := range v.allow {
+			if network.Contains(parsed) {
+				return nil
+			}
+		}
+		return &RejectedError{IP: ip, Reason: "not in any allowed range"}
+	}
+
+	for _, r := range reservedRanges {
+		if r.network.Contains(parsed) {
+			return &RejectedError{IP: ip, Reason: fmt.Sprintf("%s address (%s)", r.reason, r.network)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		allow        []string
+		deny         []string
+		recordType   string
+		ip           string
+		expectReject bool
+	}{
+		{name: "public IPv4", recordType: "A", ip: "8.8.8.8"},
+		{name: "public IPv6", recordType: "AAAA", ip: "2606:4700::1111"},
+		{name: "private", recordType: "A", ip: "10.1.2.3", expectReject: true},
+		{name: "private 172.16/12", recordType: "A", ip: "172.20.0.1", expectReject: true},
+		{name: "loopback", recordType: "A", ip: "127.0.0.1", expectReject: true},
+		{name: "CGNAT", recordType: "A", ip: "100.72.1.1", expectReject: true},
+		{name: "multicast", recordType: "A", ip: "239.1.1.1", expectReject: true},
+		{name: "documentation", recordType: "A", ip: "203.0.113.5", expectReject: true},
+		{name: "IPv6 documentation", recordType: "AAAA", ip: "2001:db8::1", expectReject: true},
+		{name: "IPv6 link-local", recordType: "AAAA", ip: "fe80::1", expectReject: true},
+		{name: "IPv6 unique local", recordType: "AAAA", ip: "fd12::1", expectReject: true},
+		{name: "IPv6 for A record", recordType: "A", ip: "2606:4700::1111", expectReject: true},
+		{name: "IPv4 for AAAA record", recordType: "AAAA", ip: "8.8.8.8", expectReject: true},
+		{name: "garbage", recordType: "A", ip: "<html>", expectReject: true},
+		{
+			name:         "denied range",
+			deny:         []string{"8.8.8.0/24"},
+			recordType:   "A",
+			ip:           "8.8.8.8",
+			expectReject: true,
+		},
+		{
+			name:       "allowed private range",
+			allow:      []string{"10.0.0.0/8"},
+			recordType: "A",
+			ip:         "10.1.2.3",
+		},
+		{
+			name:         "outside allowed ranges",
+			allow:        []string{"10.0.0.0/8"},
+			recordType:   "A",
+			ip:           "8.8.8.8",
+			expectReject: true,
+		},
+		{
+			name:         "deny wins over allow",
+			allow:        []string{"10.0.0.0/8"},
+			deny:         []string{"10.9.0.0/16"},
+			recordType:   "A",
+			ip:           "10.9.1.1",
+			expectReject: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewValidator(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = v.Validate(tt.recordType, tt.ip)
+			if !tt.expectReject {
+				if err != nil {
+					t.Errorf("expected %s to be accepted, got %v", tt.ip, err)
+				}
+				return
+			}
+			var rejected *RejectedError
+			if !errors.As(err, &rejected) {
+				t.Fatalf("expected RejectedError, got %v", err)
+			}
+			if rejected.Reason == "" {
+				t.Error("expected a rejection reason")
+			}
+		})
+	}
+}
+
+func TestNewValidatorInvalidCIDR(t *testing.T) {
+	if _, err := NewValidator([]string{"10.0.0.0"}, nil); err == nil {
+		t.Error("expected error for invalid allow CIDR")
+	}
+	if _, err := NewValidator(nil, []string{"not-a-cidr"}); err == nil {
+		t.Error("expected error for invalid deny CIDR")
+	}
+}
+
+func TestService_UpdateRejectsInvalidIP(t *testing.T) {
+	var dnsCalled, storageCalled bool
+	mockDNS := &mockDNSProvider{
+		updateRecordFunc: func(ctx context.Context, zone, name, recordType, ip string, ttl time.Duration) error {
+			dnsCalled = true
+			return nil
+		},
+	}
+	mockIP := &mockIPClient{
+		getIPFunc: func(ctx context.Context) (string, error) {
+			return "10.0.0.1", nil
+		},
+	}
+	mockStore := &mockStorage{
+		writeIPFunc: func(ip string) error {
+			storageCalled = true
+			return nil
+		},
+	}
+
+	validator, err := NewValidator(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := Config{Zone: "example.com", RecordName: "home", TTL: time.Minute, Validator: validator}
+	family := Family{RecordType: "A", IPClient: mockIP, Storage: mockStore}
+	service := New(mockDNS, []Family{family}, config)
+
+	err = service.Update(context.Background())
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expected RejectedError, got %v", err)
+	}
+	if rejected.IP != "10.0.0.1" {
+		t.Errorf("expected rejected IP 10.0.0.1, got %s", rejected.IP)
+	}
+	if dnsCalled || storageCalled {
+		t.Error("rejected address must not be published or stored")
+	}
+	if mockStore.rejection != rejected.Error() {
+		t.Errorf("expected rejection %q to be recorded, got %q", rejected.Error(), mockStore.rejection)
+	}
+
+	mockIP.getIPFunc = func(ctx context.Context) (string, error) {
+		return "8.8.8.8", nil
+	}
+	if err := service.Update(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockStore.rejection != "" {
+		t.Errorf("expected rejection to be cleared, got %q", mockStore.rejection)
+	}
+}
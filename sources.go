@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/epsilonrhorho/dns-updater/dns"
+	"github.com/epsilonrhorho/dns-updater/gateway"
+	"github.com/epsilonrhorho/dns-updater/ipify"
+)
+
+// IPSourcesConfig configures how the public IP address is discovered.
+type IPSourcesConfig struct {
+	Strategy string           `yaml:"strategy,omitempty"`
+	Timeout  time.Duration    `yaml:"timeout,omitempty"`
+	Sources  []IPSourceConfig `yaml:"sources,omitempty"`
+}
+
+// IPSourceConfig configures a single IP discovery source.
+type IPSourceConfig struct {
+	Type      string            `yaml:"type"`
+	Name      string            `yaml:"name,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Method    string            `yaml:"method,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	Username  string            `yaml:"username,omitempty"`
+	Password  string            `yaml:"password,omitempty"`
+	JSONPath  string            `yaml:"json_path,omitempty"`
+	Regex     string            `yaml:"regex,omitempty"`
+	Interface string            `yaml:"interface,omitempty"`
+	Gateway   string            `yaml:"gateway,omitempty"`
+	Location  string            `yaml:"location,omitempty"`
+	Resolvers []string          `yaml:"resolvers,omitempty"`
+	Servers   []string          `yaml:"servers,omitempty"`
+}
+
+// familyForRecordType maps a DNS record type to the address family it holds.
+func familyForRecordType(recordType string) ipify.Family {
+	if recordType == dns.RecordTypeAAAA {
+		return ipify.IPv6
+	}
+	return ipify.IPv4
+}
+
+// newIPClient builds the IP lookup client for records of the given type.
+// Without configured sources the ipify service is used.
+func newIPClient(cfg IPSourcesConfig, recordType string) (ipify.ClientInterface, error) {
+	family := familyForRecordType(recordType)
+	if len(cfg.Sources) == 0 {
+		return ipify.NewSource(ipify.SourceIpify, family)
+	}
+
+	strategy, err := ipify.ParseStrategy(cfg.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []ipify.Source
+	names := make(map[string]bool)
+	for i, sc := range cfg.Sources {
+		client, err := newIPSource(sc, family)
+		if err != nil {
+			return nil, fmt.Errorf("ip source %d: %w", i+1, err)
+		}
+		name := sc.Name
+		if name == "" {
+			name = sourceName(sc)
+		}
+		// Answers are keyed by name, so a duplicate would hide an answer
+		// and skew the majority.
+		if names[name] {
+			return nil, fmt.Errorf("ip source %d: duplicate source name %q; set a unique name", i+1, name)
+		}
+		names[name] = true
+		sources = append(sources, ipify.Source{Name: name, Client: client})
+	}
+
+	return ipify.NewMultiClient(strategy, cfg.Timeout, sources...), nil
+}
+
+// newIPSource builds a single source client for the family.
+func newIPSource(sc IPSourceConfig, family ipify.Family) (ipify.ClientInterface, error) {
+	switch strings.ToLower(sc.Type) {
+	case "http":
+		return newHTTPSource(sc, family)
+	case "interface":
+		if sc.Interface == "" {
+			return nil, fmt.Errorf("interface source requires an interface name")
+		}
+		return ipify.NewInterfaceClient(sc.Interface, family), nil
+	case "opendns":
+		return ipify.NewOpenDNSClient(family, sc.Resolvers...), nil
+	case "google-dns":
+		return ipify.NewGoogleDNSClient(family, sc.Resolvers...), nil
+	case "stun":
+		return ipify.NewSTUNClient(family, sc.Servers...), nil
+	case "upnp":
+		if family != ipify.IPv4 {
+			return nil, fmt.Errorf("upnp source only reports IPv4 addresses")
+		}
+		return gateway.NewUPnPClient(nil, sc.Location, 0), nil
+	case "natpmp", "pcp":
+		if family != ipify.IPv4 {
+			return nil, fmt.Errorf("%s source only reports IPv4 addresses", sc.Type)
+		}
+		return gateway.NewNATPMPClient(sc.Gateway, 0), nil
+	case "":
+		return nil, fmt.Errorf("source type is required")
+	default:
+		return ipify.NewSource(sc.Type, family)
+	}
+}
+
+// newHTTPSource builds a generic HTTP source. The address is read from the
+// json_path field or regex match if either is set, otherwise from the whole
+// plain-text body.
+func newHTTPSource(sc IPSourceConfig, family ipify.Family) (ipify.ClientInterface, error) {
+	if sc.URL == "" {
+		return nil, fmt.Errorf("http source requires a url")
+	}
+
+	var extractor ipify.Extractor = ipify.TextExtractor{}
+	switch {
+	case sc.JSONPath != "" && sc.Regex != "":
+		return nil, fmt.Errorf("http source accepts only one of json_path and regex")
+	case sc.JSONPath != "":
+		extractor = ipify.JSONExtractor{Path: sc.JSONPath}
+	case sc.Regex != "":
+		re, err := ipify.NewRegexExtractor(sc.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		extractor = re
+	}
+
+	request := ipify.Request{
+		Method:   strings.ToUpper(sc.Method),
+		URL:      sc.URL,
+		Headers:  sc.Headers,
+		Username: sc.Username,
+		Password: sc.Password,
+	}
+	return ipify.NewCustomClient(ipify.NewHTTPClient(family), request, extractor), nil
+}
+
+// sourceName returns the default name used to report a source in logs.
+func sourceName(sc IPSourceConfig) string {
+	if sc.URL != "" {
+		return sc.URL
+	}
+	if sc.Interface != "" {
+		return "interface:" + sc.Interface
+	}
+	return strings.ToLower(sc.Type)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/epsilonrhorho/dns-updater/ipify"
+)
+
+func TestNewIPClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      IPSourcesConfig
+		expectMulti bool
+		expectError bool
+	}{
+		{
+			name: "defaults to ipify",
+		},
+		{
+			name: "builtin and custom sources",
+			config: IPSourcesConfig{
+				Strategy: "majority",
+				Sources: []IPSourceConfig{
+					{Type: "ipify"},
+					{Type: "icanhazip"},
+					{Type: "ifconfig.me"},
+					{Type: "http", URL: "https://ip.internal.example.com/"},
+				},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "unknown strategy",
+			config: IPSourcesConfig{
+				Strategy: "quorum",
+				Sources:  []IPSourceConfig{{Type: "ipify"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "duplicate default names",
+			config: IPSourcesConfig{
+				Strategy: "majority",
+				Sources:  []IPSourceConfig{{Type: "ipify"}, {Type: "icanhazip"}, {Type: "ipify"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "duplicate URLs with distinct names",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{
+					{Type: "http", Name: "primary", URL: "https://ip.internal.example.com/"},
+					{Type: "http", Name: "secondary", URL: "https://ip.internal.example.com/"},
+				},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "duplicate explicit names",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{
+					{Type: "ipify", Name: "web"},
+					{Type: "icanhazip", Name: "web"},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "unknown source",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "whatismyip"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "DNS sources",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{
+					{Type: "opendns"},
+					{Type: "google-dns", Resolvers: []string{"ns1.google.com"}},
+				},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "STUN source",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{
+					{Type: "stun", Servers: []string{"stun.example.com:3478"}},
+				},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "interface source",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "interface", Interface: "ppp0"}},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "interface source without name",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "interface"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "http source with extraction",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{
+					{Type: "http", URL: "https://ip.internal.example.com/", JSONPath: "data.ip"},
+					{Type: "http", URL: "http://192.168.1.1/status", Regex: `WAN IP: ([0-9a-f.:]+)`, Username: "admin", Password: "secret"},
+				},
+			},
+			expectMulti: true,
+		},
+		{
+			name: "http source with json_path and regex",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "http", URL: "https://ip.example.com/", JSONPath: "ip", Regex: ".*"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "http source with invalid regex",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "http", URL: "https://ip.example.com/", Regex: "("}},
+			},
+			expectError: true,
+		},
+		{
+			name: "http source without url",
+			config: IPSourcesConfig{
+				Sources: []IPSourceConfig{{Type: "http"}},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, recordType := range []string{"A", "AAAA"} {
+				client, err := newIPClient(tt.config, recordType)
+
+				if tt.expectError {
+					if err == nil {
+						t.Errorf("expected error for %s, but got none", recordType)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("unexpected error for %s: %v", recordType, err)
+				}
+				if _, ok := client.(*ipify.MultiClient); ok != tt.expectMulti {
+					t.Errorf("expected multi client: %v, got %T", tt.expectMulti, client)
+				}
+			}
+		})
+	}
+}
+
+func TestNewIPClientGatewaySources(t *testing.T) {
+	config := IPSourcesConfig{
+		Sources: []IPSourceConfig{
+			{Type: "upnp"},
+			{Type: "natpmp", Gateway: "192.168.1.1"},
+			{Type: "pcp"},
+		},
+	}
+
+	if _, err := newIPClient(config, "A"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := newIPClient(config, "AAAA"); err == nil {
+		t.Error("expected error for IPv4-only gateway sources on AAAA records")
+	}
+}
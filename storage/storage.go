@@ -11,11 +11,27 @@ type Interface interface {
 	WriteIP(ip string) error
 }
 
+// Propagation states of the last published address.
+const (
+	StatusApplied = "applied" // served by every authoritative name server
+	StatusPending = "pending" // accepted by the provider, not yet served everywhere
+)
+
 // FileStorage implements Interface using the file system.
 type FileStorage struct {
 	path string
 }
 
+// FamilyPath returns the state file path used for the given record type.
+// A records keep using path itself so existing state carries over; other
+// types get a lowercase suffix, e.g. "/data/home.example.com.aaaa".
+func FamilyPath(path, recordType string) string {
+	if recordType == "" || strings.EqualFold(recordType, "A") {
+		return path
+	}
+	return path + "." + strings.ToLower(recordType)
+}
+
 // NewFileStorage creates a new FileStorage instance.
 func NewFileStorage(path string) *FileStorage {
 	return &FileStorage{path: path}
@@ -36,4 +52,23 @@ func (fs *FileStorage) ReadLastIP() (string, error) {
 // WriteIP writes the IP address to the file.
 func (fs *FileStorage) WriteIP(ip string) error {
 	return os.WriteFile(fs.path, []byte(ip), 0600)
-}
\ No newline at end of file
+}
+
+// WriteRejection records why the last discovered address was not published
+// next to the state file, e.g. "/data/home.example.com.rejected". An empty
+// reason removes the record once an address is accepted again.
+func (fs *FileStorage) WriteRejection(reason string) error {
+	if reason == "" {
+		if err := os.Remove(fs.path + ".rejected"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(fs.path+".rejected", []byte(reason), 0600)
+}
+
+// WriteStatus records the propagation state of the last published address
+// next to it, e.g. "/data/home.example.com.status".
+func (fs *FileStorage) WriteStatus(status string) error {
+	return os.WriteFile(fs.path+".status", []byte(status), 0600)
+}
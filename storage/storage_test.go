@@ -130,4 +130,93 @@ func TestFileStorage_ReadWriteRoundTrip(t *testing.T) {
 	if readIP != testIP {
 		t.Errorf("round trip failed: wrote %q, read %q", testIP, readIP)
 	}
-}
\ No newline at end of file
+}
+
+func TestFamilyPath(t *testing.T) {
+	tests := []struct {
+		recordType string
+		expected   string
+	}{
+		{recordType: "A", expected: "/data/home.example.com"},
+		{recordType: "", expected: "/data/home.example.com"},
+		{recordType: "AAAA", expected: "/data/home.example.com.aaaa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recordType, func(t *testing.T) {
+			if got := FamilyPath("/data/home.example.com", tt.recordType); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFileStorage_FamiliesAreIndependent(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "home.example.com")
+	v4 := NewFileStorage(FamilyPath(base, "A"))
+	v6 := NewFileStorage(FamilyPath(base, "AAAA"))
+
+	if err := v4.WriteIP("203.0.113.1"); err != nil {
+		t.Fatalf("failed to write IPv4: %v", err)
+	}
+	if err := v6.WriteIP("2001:db8::1"); err != nil {
+		t.Fatalf("failed to write IPv6: %v", err)
+	}
+
+	if ip, _ := v4.ReadLastIP(); ip != "203.0.113.1" {
+		t.Errorf("expected IPv4 state to be kept, got %q", ip)
+	}
+	if ip, _ := v6.ReadLastIP(); ip != "2001:db8::1" {
+		t.Errorf("expected IPv6 state to be kept, got %q", ip)
+	}
+}
+
+func TestFileStorage_WriteRejection(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "home.example.com")
+	fs := NewFileStorage(filePath)
+
+	reason := "refusing to publish 10.0.0.1: private address (10.0.0.0/8)"
+	if err := fs.WriteRejection(reason); err != nil {
+		t.Fatalf("failed to write rejection: %v", err)
+	}
+	content, err := os.ReadFile(filePath + ".rejected")
+	if err != nil {
+		t.Fatalf("failed to read rejection file: %v", err)
+	}
+	if string(content) != reason {
+		t.Errorf("expected rejection %q, got %q", reason, string(content))
+	}
+
+	if err := fs.WriteRejection(""); err != nil {
+		t.Fatalf("failed to clear rejection: %v", err)
+	}
+	if _, err := os.Stat(filePath + ".rejected"); !os.IsNotExist(err) {
+		t.Errorf("expected rejection file to be removed, got %v", err)
+	}
+	if err := fs.WriteRejection(""); err != nil {
+		t.Errorf("clearing a missing rejection failed: %v", err)
+	}
+}
+
+func TestFileStorage_WriteStatus(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "home.example.com")
+	fs := NewFileStorage(filePath)
+
+	if err := fs.WriteIP("203.0.113.1"); err != nil {
+		t.Fatalf("failed to write IP: %v", err)
+	}
+	if err := fs.WriteStatus(StatusPending); err != nil {
+		t.Fatalf("failed to write status: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath + ".status")
+	if err != nil {
+		t.Fatalf("failed to read status file: %v", err)
+	}
+	if string(content) != StatusPending {
+		t.Errorf("expected status %q, got %q", StatusPending, string(content))
+	}
+	if ip, _ := fs.ReadLastIP(); ip != "203.0.113.1" {
+		t.Errorf("expected IP to be kept, got %q", ip)
+	}
+}